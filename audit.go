@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hegner123/init/initcore"
+)
+
+// auditEntry is one line of the --audit-log file.
+type auditEntry struct {
+	Time      string `json:"time"`
+	Directory string `json:"directory"`
+	Dest      string `json:"dest"`
+	Action    string `json:"action"`
+	Bytes     int64  `json:"bytes"`
+	SHA256    string `json:"sha256,omitempty"`
+}
+
+// auditLog appends one JSON line per file operation to a file. Each line
+// is synced to disk as it is written, so nothing is lost when the CLI
+// exits.
+type auditLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// openAuditLog opens path for appending, creating it if needed.
+func openAuditLog(path string) (*auditLog, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+	return &auditLog{f: f}, nil
+}
+
+// record appends ev, which happened in directory. Failures are reported on
+// stderr; they do not stop the run.
+func (a *auditLog) record(directory string, ev initcore.FileEvent) {
+	dest := ev.File
+	if !filepath.IsAbs(dest) {
+		dest = filepath.Join(directory, dest)
+	}
+	line, err := json.Marshal(auditEntry{
+		Time:      time.Now().UTC().Format(time.RFC3339Nano),
+		Directory: directory,
+		Dest:      dest,
+		Action:    ev.Status,
+		Bytes:     ev.Bytes,
+		SHA256:    ev.SHA256,
+	})
+	if err == nil {
+		a.mu.Lock()
+		_, err = a.f.Write(append(line, '\n'))
+		if err == nil {
+			err = a.f.Sync()
+		}
+		a.mu.Unlock()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: writing audit log: %v\n", err)
+	}
+}
+
+// observe returns opts with OnFile extended to record each event in a,
+// after calling any existing OnFile.
+func (a *auditLog) observe(opts initcore.Options, directory string) initcore.Options {
+	next := opts.OnFile
+	opts.OnFile = func(ev initcore.FileEvent) {
+		if next != nil {
+			next(ev)
+		}
+		a.record(directory, ev)
+	}
+	return opts
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hegner123/init/initcore"
+)
+
+// auditActions returns the dest and action of each line in the audit log
+// at path, as "DEST ACTION" relative to directory.
+func auditActions(t *testing.T, path, directory string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry auditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		rel, err := filepath.Rel(directory, entry.Dest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actions = append(actions, rel+" "+entry.Action)
+	}
+	return actions
+}
+
+func TestAuditLogRemovals(t *testing.T) {
+	files := []initcore.File{{Content: []byte("license\n"), DestName: "LICENSE"}}
+
+	tests := []struct {
+		name  string
+		opts  cliOptions
+		stale string
+		want  []string
+	}{
+		{
+			name: "clean",
+			opts: cliOptions{Clean: true},
+			want: []string{"LICENSE removed"},
+		},
+		{
+			name:  "clean-dir",
+			opts:  cliOptions{CleanDir: true},
+			stale: "LICENSE.old",
+			want:  []string{"LICENSE.old removed", "LICENSE created"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			coreOpts := initcore.Options{Files: files, CleanDir: tt.opts.CleanDir}
+			if tt.stale == "" {
+				if _, err := initcore.WriteFiles(dir, coreOpts); err != nil {
+					t.Fatal(err)
+				}
+			} else if err := os.WriteFile(filepath.Join(dir, tt.stale), []byte("license\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			logPath := filepath.Join(t.TempDir(), "audit.log")
+			audit, err := openAuditLog(logPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := runDirectory(tt.opts, audit.observe(coreOpts, dir), dir); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := auditActions(t, logPath, dir); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("audit log = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
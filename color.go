@@ -0,0 +1,29 @@
+package main
+
+import "os"
+
+// ANSI SGR codes used in text output.
+const (
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorReset = "\x1b[0m"
+)
+
+// errorLabel starts every CLI error message on stderr. main colors it red
+// once it knows stderr is a terminal that accepts color.
+var errorLabel = "Error: "
+
+// useColor reports whether output written to f may contain color codes:
+// f must be a terminal, and neither --no-color nor NO_COLOR
+// (https://no-color.org) may be set.
+func useColor(f *os.File, noColor bool) bool {
+	return !noColor && os.Getenv("NO_COLOR") == "" && isTerminal(f)
+}
+
+// colorize wraps s in the given color when enabled is set.
+func colorize(enabled bool, color, s string) string {
+	if !enabled {
+		return s
+	}
+	return color + s + colorReset
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// completionShells are the shells `init completion` can emit a script for.
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completionFlag is one registered flag as the completion scripts see it.
+type completionFlag struct {
+	name   string
+	usage  string
+	isBool bool
+
+	// values, if set, are the fixed choices for the flag's argument, and
+	// dir marks an argument that names a directory.
+	values []string
+	dir    bool
+
+	// repeat marks a flag that may be given more than once.
+	repeat bool
+}
+
+// completionFlags returns every flag registered on fs, in lexical order,
+// with the known argument values filled in.
+func completionFlags(fs *flag.FlagSet) []completionFlag {
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		cf := completionFlag{name: f.Name, usage: f.Usage}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			cf.isBool = true
+		}
+		switch f.Value.(type) {
+		case *listFlag, mapFlag:
+			cf.repeat = true
+		}
+		switch f.Name {
+		case "profile":
+			cf.values = slices.Sorted(maps.Keys(profiles))
+		case "cat":
+			cf.values = templateNames()
+		case "output-format":
+			cf.values = []string{FormatText, FormatJSON}
+		case "framing":
+			cf.values = []string{FramingNewline, FramingContentLength}
+		case "directory":
+			cf.dir = true
+		}
+		flags = append(flags, cf)
+	})
+	return flags
+}
+
+// templateNames returns the sorted destination names of the files in every
+// profile.
+func templateNames() []string {
+	var names []string
+	for _, files := range profiles {
+		for _, f := range files {
+			names = append(names, f.DestName)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// writeCompletion writes the completion script for shell to w, generated
+// from the flags registered on fs.
+func writeCompletion(w io.Writer, shell string, fs *flag.FlagSet) error {
+	flags := completionFlags(fs)
+	switch shell {
+	case "bash":
+		writeBashCompletion(w, flags)
+	case "zsh":
+		writeZshCompletion(w, flags)
+	case "fish":
+		writeFishCompletion(w, flags)
+	default:
+		return fmt.Errorf("unknown shell %q (want %s)", shell, strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+func writeBashCompletion(w io.Writer, flags []completionFlag) {
+	var names []string
+	fmt.Fprintln(w, "# bash completion for init")
+	fmt.Fprintln(w, "_init_completion() {")
+	fmt.Fprintln(w, `    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintln(w, `    if [[ $COMP_CWORD -eq 2 && ${COMP_WORDS[1]} == completion ]]; then`)
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(completionShells, " "))
+	fmt.Fprintln(w, "        return")
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintln(w, `    case "$prev" in`)
+	for _, f := range flags {
+		names = append(names, "--"+f.name)
+		switch {
+		case f.values != nil:
+			fmt.Fprintf(w, "        --%s|-%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", f.name, f.name, strings.Join(f.values, " "))
+		case f.dir:
+			fmt.Fprintf(w, "        --%s|-%s) COMPREPLY=($(compgen -d -- \"$cur\")); return ;;\n", f.name, f.name)
+		case !f.isBool:
+			fmt.Fprintf(w, "        --%s|-%s) return ;;\n", f.name, f.name)
+		}
+	}
+	fmt.Fprintln(w, "    esac")
+	fmt.Fprintln(w, `    if [[ $COMP_CWORD -eq 1 && $cur != -* ]]; then`)
+	fmt.Fprintln(w, `        COMPREPLY=($(compgen -W "completion" -- "$cur"))`)
+	fmt.Fprintln(w, "        return")
+	fmt.Fprintln(w, "    fi")
+	fmt.Fprintf(w, "    COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -F _init_completion init")
+}
+
+func writeZshCompletion(w io.Writer, flags []completionFlag) {
+	// Descriptions sit inside [...] in a single-quoted word.
+	desc := strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`)
+
+	fmt.Fprintln(w, "#compdef init")
+	fmt.Fprintln(w, "_arguments \\")
+	for _, f := range flags {
+		// A leading * lets zsh offer a repeatable flag again.
+		repeat := ""
+		if f.repeat {
+			repeat = "*"
+		}
+		switch {
+		case f.isBool:
+			fmt.Fprintf(w, "  '%s--%s[%s]' \\\n", repeat, f.name, desc.Replace(f.usage))
+		case f.values != nil:
+			fmt.Fprintf(w, "  '%s--%s=[%s]:%s:(%s)' \\\n", repeat, f.name, desc.Replace(f.usage), f.name, strings.Join(f.values, " "))
+		case f.dir:
+			fmt.Fprintf(w, "  '%s--%s=[%s]:%s:_directories' \\\n", repeat, f.name, desc.Replace(f.usage), f.name)
+		default:
+			fmt.Fprintf(w, "  '%s--%s=[%s]:%s:' \\\n", repeat, f.name, desc.Replace(f.usage), f.name)
+		}
+	}
+	fmt.Fprintf(w, "  '1::subcommand:(completion)' \\\n")
+	fmt.Fprintf(w, "  '2::shell:(%s)'\n", strings.Join(completionShells, " "))
+}
+
+func writeFishCompletion(w io.Writer, flags []completionFlag) {
+	quote := strings.NewReplacer(`\`, `\\`, "'", `\'`)
+
+	fmt.Fprintln(w, "# fish completion for init")
+	fmt.Fprintln(w, "complete -c init -n __fish_use_subcommand -f -a completion -d 'Print a shell completion script'")
+	fmt.Fprintf(w, "complete -c init -n '__fish_seen_subcommand_from completion' -f -a '%s'\n", strings.Join(completionShells, " "))
+	for _, f := range flags {
+		line := fmt.Sprintf("complete -c init -l %s", f.name)
+		switch {
+		case f.values != nil:
+			line += fmt.Sprintf(" -x -a '%s'", strings.Join(f.values, " "))
+		case f.dir:
+			line += " -x -a '(__fish_complete_directories)'"
+		case !f.isBool:
+			line += " -r"
+		}
+		fmt.Fprintf(w, "%s -d '%s'\n", line, quote.Replace(f.usage))
+	}
+}
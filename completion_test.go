@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestZshCompletion(t *testing.T) {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	var directories, exclude listFlag
+	fs.Var(&directories, "directory", "Target directory")
+	fs.Var(&exclude, "exclude", "Skip PATTERN")
+	fs.Var(mapFlag{}, "rename", "Rename OLD=NEW")
+	fs.String("cat", "", "Print DESTNAME")
+	fs.Bool("force", false, "Overwrite")
+
+	var b strings.Builder
+	if err := writeCompletion(&b, "zsh", fs); err != nil {
+		t.Fatal(err)
+	}
+	script := b.String()
+
+	for _, want := range []string{
+		"'*--directory=[",
+		"'*--exclude=[",
+		"'*--rename=[",
+		"'--force[",
+		"'--cat=[Print DESTNAME]:cat:(" + strings.Join(templateNames(), " ") + ")'",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("zsh script lacks %q:\n%s", want, script)
+		}
+	}
+}
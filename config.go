@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"github.com/hegner123/init/initcore"
+)
+
+// effectiveConfig is the fully resolved configuration of a CLI run, as
+// printed by --print-config.
+type effectiveConfig struct {
+	Mode         string            `json:"mode"`
+	Directories  []string          `json:"directories"`
+	WaitForDir   string            `json:"wait_for_directory,omitempty"`
+	Profile      string            `json:"profile"`
+	Prefix       string            `json:"prefix,omitempty"`
+	TemplateURL  string            `json:"template_url,omitempty"`
+	TemplateSet  string            `json:"template_set,omitempty"`
+	RegistryURL  string            `json:"registry_url,omitempty"`
+	FromStdin    string            `json:"from_stdin,omitempty"`
+	Files        []configFile      `json:"files"`
+	Renames      map[string]string `json:"renames,omitempty"`
+	DestMap      string            `json:"dest_map,omitempty"`
+	Variables    map[string]string `json:"variables,omitempty"`
+	VarSubdirs   bool              `json:"var_subdirs"`
+	Exclude      []string          `json:"exclude,omitempty"`
+	Transforms   []configTransform `json:"transforms,omitempty"`
+	Enable       []string          `json:"enable,omitempty"`
+	ExpandEnv    bool              `json:"expand_env"`
+	Force        bool              `json:"force"`
+	KeepNewer    bool              `json:"no_clobber_newer"`
+	NewerThan    string            `json:"newer_than,omitempty"`
+	CleanDir     bool              `json:"clean_dir"`
+	IfEmpty      bool              `json:"if_empty"`
+	EmptyIgnore  []string          `json:"if_empty_ignore,omitempty"`
+	Incremental  bool              `json:"incremental"`
+	FollowLinks  bool              `json:"follow_symlinks"`
+	Append       bool              `json:"append"`
+	AppendMarker string            `json:"append_marker,omitempty"`
+	EnsureEOL    bool              `json:"ensure_trailing_newline"`
+	AllowExtra   bool              `json:"allow_extra"`
+	MaxTotalSize int64             `json:"max_total_size"`
+	MaxFileSize  int64             `json:"max_file_size"`
+	ModTime      string            `json:"mtime"`
+	Owner        string            `json:"owner,omitempty"`
+	Retries      int               `json:"retries"`
+	RelativePath bool              `json:"relative_paths"`
+	OutputFormat string            `json:"output_format"`
+	Quiet        bool              `json:"quiet"`
+	NoColor      bool              `json:"no_color"`
+	GitInit      bool              `json:"git_init"`
+	GitAdd       bool              `json:"git_add"`
+	AuditLog     string            `json:"audit_log,omitempty"`
+	Manifest     string            `json:"write_manifest,omitempty"`
+
+	ContinueOnError bool `json:"continue_on_error"`
+}
+
+// configFile describes one file in the resolved file set.
+type configFile struct {
+	DestName     string   `json:"dest_name"`
+	Destinations []string `json:"destinations"`
+	Size         int      `json:"size"`
+	Mode         string   `json:"mode"`
+	Excluded     bool     `json:"excluded,omitempty"`
+
+	// RequiresFlag and Enabled describe optional files.
+	RequiresFlag string `json:"requires_flag,omitempty"`
+	Enabled      bool   `json:"enabled"`
+}
+
+// configTransform is one --transform rule.
+type configTransform struct {
+	Pattern string `json:"pattern"`
+	Command string `json:"command"`
+}
+
+// resolveConfig merges the CLI options with the resolved file set.
+func resolveConfig(opts cliOptions, files []initcore.File) effectiveConfig {
+	var waitForDir string
+	if opts.WaitForDir > 0 {
+		waitForDir = opts.WaitForDir.String()
+	}
+	cfg := effectiveConfig{
+		Mode:         cliMode(opts),
+		Directories:  opts.Directories,
+		WaitForDir:   waitForDir,
+		Profile:      opts.Profile,
+		Prefix:       opts.Prefix,
+		TemplateURL:  opts.TemplateURL,
+		TemplateSet:  opts.TemplateSet,
+		RegistryURL:  opts.RegistryURL,
+		FromStdin:    opts.FromStdin,
+		Files:        make([]configFile, 0, len(files)),
+		Renames:      opts.Renames,
+		DestMap:      opts.DestMap,
+		Variables:    opts.Variables,
+		VarSubdirs:   opts.VarSubdirs,
+		Exclude:      opts.Exclude,
+		Enable:       opts.Enable,
+		ExpandEnv:    opts.ExpandEnv,
+		Force:        opts.Force,
+		KeepNewer:    opts.KeepNewer,
+		NewerThan:    opts.NewerThan,
+		CleanDir:     opts.CleanDir,
+		IfEmpty:      opts.IfEmpty,
+		EmptyIgnore:  opts.EmptyIgnore,
+		Incremental:  opts.Incremental,
+		FollowLinks:  opts.FollowLinks,
+		Append:       opts.Append,
+		AppendMarker: opts.AppendMarker,
+		EnsureEOL:    opts.EnsureEOL,
+		AllowExtra:   opts.AllowExtra,
+		MaxTotalSize: opts.MaxTotalSize,
+		MaxFileSize:  opts.MaxFileSize,
+		ModTime:      opts.ModTime,
+		Owner:        opts.Owner,
+		Retries:      opts.Retries,
+		RelativePath: opts.RelativePath,
+		OutputFormat: opts.OutputFormat,
+		Quiet:        opts.Quiet,
+		NoColor:      opts.NoColor,
+		GitInit:      opts.GitInit,
+		GitAdd:       opts.GitAdd,
+		AuditLog:     opts.AuditLog,
+		Manifest:     opts.Manifest,
+
+		ContinueOnError: opts.ContinueOnError,
+	}
+	for _, t := range opts.Transforms {
+		cfg.Transforms = append(cfg.Transforms, configTransform{Pattern: t.Pattern, Command: t.Command})
+	}
+	for _, f := range files {
+		excluded := slices.ContainsFunc(opts.Exclude, func(p string) bool {
+			ok, _ := initcore.MatchGlob(p, f.DestName)
+			return ok
+		})
+		destinations := make([]string, len(opts.Directories))
+		for i, directory := range opts.Directories {
+			destinations[i] = filepath.Join(directory, f.DestName)
+		}
+		cfg.Files = append(cfg.Files, configFile{
+			DestName:     f.DestName,
+			Destinations: destinations,
+			Size:         len(f.Content),
+			Mode:         fmt.Sprintf("%#o", initcore.FileMode.Perm()),
+			Excluded:     excluded,
+
+			RequiresFlag: f.RequiresFlag,
+			Enabled:      f.RequiresFlag == "" || slices.Contains(opts.Enable, f.RequiresFlag),
+		})
+	}
+	return cfg
+}
+
+// cliMode names the operation a CLI run would perform.
+func cliMode(opts cliOptions) string {
+	switch {
+	case opts.Count:
+		return "count"
+	case opts.Verify:
+		return "verify"
+	case opts.Clean && opts.DryRun:
+		return "clean (dry run)"
+	case opts.Clean:
+		return "clean"
+	case opts.DryRun:
+		return "dry-run"
+	default:
+		return "write"
+	}
+}
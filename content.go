@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"unicode/utf8"
+)
+
+// isBinary reports whether content should be treated as binary: it is not
+// valid UTF-8 or contains a NUL byte.
+func isBinary(content []byte) bool {
+	return !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0
+}
+
+// resourceItem wraps content as an embedded resource, as text when it is
+// text and base64-encoded otherwise. The MIME type comes from the URI's
+// extension, falling back to sniffing the content.
+func resourceItem(uri string, content []byte) ContentItem {
+	mimeType := mime.TypeByExtension(path.Ext(uri))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(content)
+	}
+
+	res := &ResourceContents{URI: uri, MimeType: mimeType}
+	if isBinary(content) {
+		res.Blob = base64.StdEncoding.EncodeToString(content)
+	} else {
+		res.Text = string(content)
+	}
+	return ContentItem{Type: "resource", Resource: res}
+}
+
+// templateURI names an embedded template as a resource.
+func templateURI(name string) string {
+	return "init://templates/" + url.PathEscape(name)
+}
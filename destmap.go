@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// loadDestMap reads a --dest-map file: either a JSON object mapping old
+// DestNames to new ones, or lines of OLD=NEW where blank lines and lines
+// starting with # are skipped. A source listed twice, or two sources mapped
+// to the same target, is an error.
+func loadDestMap(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	destMap := make(map[string]string)
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("{")) {
+		if err := json.Unmarshal(trimmed, &destMap); err != nil {
+			return nil, fmt.Errorf("parsing JSON: %w", err)
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" || strings.HasPrefix(text, "#") {
+				continue
+			}
+			oldName, newName, ok := strings.Cut(text, "=")
+			oldName, newName = strings.TrimSpace(oldName), strings.TrimSpace(newName)
+			if !ok || oldName == "" || newName == "" {
+				return nil, fmt.Errorf("line %d: expected OLD=NEW, got %q", line, text)
+			}
+			if _, dup := destMap[oldName]; dup {
+				return nil, fmt.Errorf("line %d: %s is mapped more than once", line, oldName)
+			}
+			destMap[oldName] = newName
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	sources := make(map[string]string, len(destMap))
+	for oldName, newName := range destMap {
+		if newName == "" {
+			return nil, fmt.Errorf("%s is mapped to an empty name", oldName)
+		}
+		if prev, ok := sources[newName]; ok {
+			first, second := min(prev, oldName), max(prev, oldName)
+			return nil, fmt.Errorf("duplicate target: %s and %s both map to %s", first, second, newName)
+		}
+		sources[newName] = oldName
+	}
+	return destMap, nil
+}
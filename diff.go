@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxDiffLines caps the number of changed lines shown by shortDiff.
+	maxDiffLines = 40
+	// maxDiffInputLines caps the lines on either side that shortDiff will
+	// match; the table it builds grows with the product of the two.
+	maxDiffInputLines = 2000
+	// maxDiffSize caps the bytes on either side that previewContent reads
+	// for a diff.
+	maxDiffSize = 1 << 20
+)
+
+// shortDiff returns the lines removed from old ("-") and added in new ("+"),
+// in order, based on a longest-common-subsequence match of whole lines. At
+// most maxDiffLines changed lines are shown. If either side has more than
+// maxDiffInputLines lines, only a summary is returned.
+func shortDiff(old, new string) string {
+	a := strings.SplitAfter(old, "\n")
+	b := strings.SplitAfter(new, "\n")
+	if len(a) > maxDiffInputLines || len(b) > maxDiffInputLines {
+		return tooLargeToDiff(fmt.Sprintf("%d and %d lines, over the %d-line limit", len(a), len(b), maxDiffInputLines))
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+strings.TrimSuffix(a[i], "\n"))
+			i++
+		default:
+			lines = append(lines, "+"+strings.TrimSuffix(b[j], "\n"))
+			j++
+		}
+	}
+
+	if len(lines) > maxDiffLines {
+		more := len(lines) - maxDiffLines
+		lines = append(lines[:maxDiffLines], fmt.Sprintf("... %d more changed lines", more))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// tooLargeToDiff is the summary shown in place of a diff that was not
+// computed, with detail saying why.
+func tooLargeToDiff(detail string) string {
+	return fmt.Sprintf("(file too large to diff: %s)", detail)
+}
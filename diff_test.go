@@ -0,0 +1,21 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestShortDiff(t *testing.T) {
+	got := shortDiff("a\nb\nc\n", "a\nx\nc\n")
+	if want := "-b\n+x"; got != want {
+		t.Errorf("shortDiff = %q, want %q", got, want)
+	}
+}
+
+func TestShortDiffTooLarge(t *testing.T) {
+	large := strings.Repeat("line\n", maxDiffInputLines+1)
+	got := shortDiff(large, "line\n")
+	if !strings.HasPrefix(got, "(file too large to diff") {
+		t.Errorf("shortDiff = %q, want a too-large summary", got)
+	}
+}
@@ -1,3 +1,3 @@
-module init
+module github.com/hegner123/init
 
 go 1.25.0
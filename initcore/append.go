@@ -0,0 +1,44 @@
+package initcore
+
+import "bytes"
+
+// appends reports whether f is appended to, rather than colliding with, an
+// existing destination. A Policy other than PolicyAppend overrides
+// File.Append and Options.Append.
+func (opts Options) appends(f File) bool {
+	switch f.Policy {
+	case "":
+		return opts.Append || f.Append
+	case PolicyAppend:
+		return true
+	}
+	return false
+}
+
+// appendBlock returns the block appended for content: content itself,
+// preceded by opts.AppendMarker on its own line when a marker is set.
+func (opts Options) appendBlock(content []byte) []byte {
+	if opts.AppendMarker == "" {
+		return content
+	}
+	return append([]byte(opts.AppendMarker+"\n"), content...)
+}
+
+// appended reports whether existing already holds the block for content:
+// the marker line if one is set, otherwise the content itself.
+func (opts Options) appended(existing, content []byte) bool {
+	if opts.AppendMarker != "" {
+		return bytes.Contains(existing, []byte(opts.AppendMarker))
+	}
+	return bytes.Contains(existing, content)
+}
+
+// appendTo returns existing followed by the block for content, starting the
+// block on a new line.
+func (opts Options) appendTo(existing, content []byte) []byte {
+	out := bytes.Clone(existing)
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		out = append(out, '\n')
+	}
+	return append(out, opts.appendBlock(content)...)
+}
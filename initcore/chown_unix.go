@@ -0,0 +1,7 @@
+//go:build unix
+
+package initcore
+
+// ChownSupported reports whether Options.Owner takes effect on this
+// platform.
+const ChownSupported = true
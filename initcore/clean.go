@@ -0,0 +1,85 @@
+package initcore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// Clean removes from dir each file in opts.Files whose content still
+// matches the template after variable substitution, undoing a previous
+// WriteFiles. Files that have been modified are left in place and reported
+// in FilesSkipped; files that are already gone are ignored. Each removed or
+// skipped file is reported to opts.OnFile. It is shorthand
+// for CleanContext with context.Background.
+func Clean(dir string, opts Options) (*Result, error) {
+	return CleanContext(context.Background(), dir, opts)
+}
+
+// CleanContext is Clean, stopping with an error if ctx (checked between
+// files) is cancelled. Files already removed stay removed; they matched
+// the templates, so a later run writes them again.
+func CleanContext(ctx context.Context, dir string, opts Options) (*Result, error) {
+	if err := checkDirectory(dir); err != nil {
+		return nil, err
+	}
+
+	enabled, err := Decompress(opts.enabledFiles())
+	if err != nil {
+		return nil, err
+	}
+	enabled, err = SubstituteNames(enabled, opts.Variables, opts.VariableSubdirs)
+	if err != nil {
+		return nil, err
+	}
+	files, _, err := partition(enabled, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		SchemaVersion: ResultSchemaVersion,
+		Directory:     dir,
+		DryRun:        opts.DryRun,
+	}
+
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("cancelled after removing %d files: %w", len(result.FilesRemoved), err)
+		}
+
+		destPath, err := resolvePath(dir, f.DestName)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := os.ReadFile(destPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", f.DestName, err)
+		}
+
+		shown := opts.reportPath(dir, destPath)
+		if !bytes.Equal(content, opts.Render(f)) {
+			result.FilesSkipped = append(result.FilesSkipped, shown)
+			opts.notify(FileEvent{File: shown, Status: StatusSkipped})
+			continue
+		}
+
+		if !opts.DryRun {
+			if err := os.Remove(destPath); err != nil {
+				return nil, fmt.Errorf("removing %s: %w", f.DestName, err)
+			}
+		}
+		result.FilesRemoved = append(result.FilesRemoved, shown)
+		opts.notify(FileEvent{File: shown, Status: StatusRemoved, Bytes: int64(len(content)), SHA256: hashContent(content)})
+	}
+
+	result.sortFiles()
+	return result, nil
+}
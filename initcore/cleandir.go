@@ -0,0 +1,61 @@
+package initcore
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// staleFiles returns the regular files in dir that Options.CleanDir
+// removes: every existing destination in files, plus any top-level file
+// whose content equals one of the rendered templates, which catches copies
+// written earlier under another name. The ignore file, the lock file and
+// anything excluded or ignored are never included.
+func (opts Options) staleFiles(dir string, files []File) ([]string, error) {
+	var stale []string
+	rendered := make([][]byte, 0, len(files))
+	for _, f := range files {
+		destPath, err := resolvePath(dir, f.DestName)
+		if err != nil {
+			return nil, err
+		}
+		if info, err := os.Lstat(destPath); err == nil && info.Mode().IsRegular() {
+			stale = append(stale, destPath)
+		}
+		rendered = append(rendered, opts.Render(f))
+	}
+
+	patterns, err := readIgnoreFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading directory: %w", err)
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !entry.Type().IsRegular() || slices.Contains(stale, path) {
+			continue
+		}
+		if opts.isStateFile(entry.Name()) {
+			continue
+		}
+		if excluded, _ := matchAny(opts.Exclude, entry.Name()); excluded {
+			continue
+		}
+		if ignored, _ := matchAny(patterns, entry.Name()); ignored {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", entry.Name(), err)
+		}
+		if slices.ContainsFunc(rendered, func(r []byte) bool { return bytes.Equal(r, content) }) {
+			stale = append(stale, path)
+		}
+	}
+	return stale, nil
+}
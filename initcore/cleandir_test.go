@@ -0,0 +1,67 @@
+package initcore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCleanDirRefusesBeforeRemoving(t *testing.T) {
+	dir := t.TempDir()
+	edited := filepath.Join(dir, "CONTRIBUTING.md")
+	if err := os.WriteFile(edited, []byte("edited\n"), FileMode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(t.TempDir(), "elsewhere"), filepath.Join(dir, "LICENSE")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	_, err := WriteFiles(dir, Options{
+		Files: []File{
+			{Content: []byte("contributing\n"), DestName: "CONTRIBUTING.md"},
+			{Content: []byte("license\n"), DestName: "LICENSE"},
+		},
+		CleanDir: true,
+	})
+	if !errors.Is(err, ErrSymlink) {
+		t.Fatalf("err = %v, want ErrSymlink", err)
+	}
+	got, err := os.ReadFile(edited)
+	if err != nil {
+		t.Fatalf("edited file was removed: %v", err)
+	}
+	if string(got) != "edited\n" {
+		t.Errorf("edited file = %q, want it unchanged", got)
+	}
+}
+
+func TestCleanDirRestoresRemovedFilesOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	edited := filepath.Join(dir, "CONTRIBUTING.md")
+	if err := os.WriteFile(edited, []byte("edited\n"), FileMode); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second write fails after CONTRIBUTING.md has been removed and
+	// rewritten.
+	fsys := &failingFS{failWrite: filepath.Join(dir, "LICENSE")}
+	_, err := WriteFiles(dir, Options{
+		Files: []File{
+			{Content: []byte("contributing\n"), DestName: "CONTRIBUTING.md"},
+			{Content: []byte("license\n"), DestName: "LICENSE"},
+		},
+		CleanDir: true,
+		FS:       fsys,
+	})
+	if !errors.Is(err, errInjected) {
+		t.Fatalf("err = %v, want the injected failure", err)
+	}
+	got, err := os.ReadFile(edited)
+	if err != nil || string(got) != "edited\n" {
+		t.Errorf("CONTRIBUTING.md = %q, %v; want the original restored", got, err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "LICENSE")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LICENSE left behind after rollback: %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package initcore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// Decompress returns a copy of files in which every File with Compressed
+// set holds its gunzipped content instead. Files that are not compressed
+// are passed through unchanged.
+func Decompress(files []File) ([]File, error) {
+	out := make([]File, len(files))
+	for i, f := range files {
+		if f.Compressed {
+			content, err := gunzip(f.Content)
+			if err != nil {
+				return nil, fmt.Errorf("decompressing %s: %w", f.DestName, err)
+			}
+			f.Content, f.Compressed = content, false
+		}
+		out[i] = f
+	}
+	return out, nil
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
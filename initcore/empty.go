@@ -0,0 +1,53 @@
+package initcore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrNotEmpty is returned when Options.RequireEmpty is set and the target
+// directory already has entries.
+var ErrNotEmpty = errors.New("directory is not empty")
+
+// DefaultEmptyIgnore lists the entries a directory may contain and still
+// count as empty for Options.RequireEmpty when Options.EmptyIgnore is nil.
+var DefaultEmptyIgnore = []string{".git"}
+
+// checkEmpty returns ErrNotEmpty, naming the first few offending entries,
+// if opts.RequireEmpty is set and dir holds anything other than entries
+// matching opts.EmptyIgnore.
+func (opts Options) checkEmpty(dir string) error {
+	if !opts.RequireEmpty {
+		return nil
+	}
+	ignore := opts.EmptyIgnore
+	if ignore == nil {
+		ignore = DefaultEmptyIgnore
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("reading directory: %w", err)
+	}
+	var found []string
+	for _, entry := range entries {
+		ok, err := matchAny(ignore, entry.Name())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			found = append(found, entry.Name())
+		}
+	}
+
+	switch {
+	case len(found) == 0:
+		return nil
+	case len(found) > 3:
+		return fmt.Errorf("%w: %s contains %s and %d more", ErrNotEmpty, dir, strings.Join(found[:3], ", "), len(found)-3)
+	default:
+		return fmt.Errorf("%w: %s contains %s", ErrNotEmpty, dir, strings.Join(found, ", "))
+	}
+}
@@ -0,0 +1,50 @@
+package initcore
+
+import (
+	"io/fs"
+	"os"
+	"time"
+)
+
+// FS is the set of filesystem operations WriteFilesContext performs on
+// destinations: reading and writing them, creating parent directories,
+// CleanDir removals, and undoing all of that on rollback. FS exists so
+// tests can inject failures in those steps, such as a write that fails
+// partway through a run.
+//
+// Everything else uses the real filesystem: checking the target directory,
+// RunLockFileName, LockFileName, the ignore file, CleanDir's directory
+// scan and path validation. The target directory must therefore exist on
+// disk even when FS is not the real filesystem.
+type FS interface {
+	Stat(name string) (fs.FileInfo, error)
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	Mkdir(name string, perm fs.FileMode) error
+	Remove(name string) error
+	Chtimes(name string, atime, mtime time.Time) error
+	Chown(name string, uid, gid int) error
+}
+
+// osFS implements FS with the os package.
+type osFS struct{}
+
+func (osFS) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+func (osFS) ReadFile(name string) ([]byte, error)  { return os.ReadFile(name) }
+func (osFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+func (osFS) Mkdir(name string, perm fs.FileMode) error { return os.Mkdir(name, perm) }
+func (osFS) Remove(name string) error                  { return os.Remove(name) }
+func (osFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+func (osFS) Chown(name string, uid, gid int) error { return os.Chown(name, uid, gid) }
+
+// fs returns opts.FS, or the real filesystem if it is nil.
+func (opts Options) fs() FS {
+	if opts.FS != nil {
+		return opts.FS
+	}
+	return osFS{}
+}
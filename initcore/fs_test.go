@@ -0,0 +1,56 @@
+package initcore
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// errInjected is the error failingFS returns.
+var errInjected = errors.New("injected failure")
+
+// failingFS is the real filesystem, except that writing failWrite fails.
+type failingFS struct {
+	osFS
+	failWrite string
+}
+
+func (f *failingFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if name == f.failWrite {
+		return errInjected
+	}
+	return f.osFS.WriteFile(name, data, perm)
+}
+
+func TestWriteFilesRollsBackMidWriteFailure(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "README.md")
+	if err := os.WriteFile(existing, []byte("original\n"), FileMode); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := WriteFiles(dir, Options{
+		Files: []File{
+			{Content: []byte("new\n"), DestName: "README.md"},
+			{Content: []byte("nested\n"), DestName: "docs/guide.md"},
+			{Content: []byte("license\n"), DestName: "LICENSE"},
+		},
+		Force: true,
+		FS:    &failingFS{failWrite: filepath.Join(dir, "LICENSE")},
+	})
+	if !errors.Is(err, errInjected) {
+		t.Fatalf("err = %v, want the injected failure", err)
+	}
+
+	got, err := os.ReadFile(existing)
+	if err != nil || string(got) != "original\n" {
+		t.Errorf("README.md = %q, %v; want the original restored", got, err)
+	}
+	for _, name := range []string{"docs/guide.md", "docs", "LICENSE"} {
+		if _, err := os.Lstat(filepath.Join(dir, name)); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("%s left behind after rollback: %v", name, err)
+		}
+	}
+}
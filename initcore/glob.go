@@ -0,0 +1,56 @@
+package initcore
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MatchGlob reports whether name matches the slash-separated glob pattern.
+// Each segment is matched with path.Match, and a "**" segment matches zero
+// or more whole segments. A pattern without a slash is matched against the
+// last element of name, so "*.tmp" matches "a/b.tmp".
+func MatchGlob(pattern, name string) (bool, error) {
+	name = strings.TrimPrefix(path.Clean(filepath.ToSlash(name)), "./")
+	if !strings.Contains(pattern, "/") {
+		return path.Match(pattern, path.Base(name))
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if ok, err := matchSegments(pattern[1:], name[i:]); ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+		if len(name) == 0 {
+			return false, nil
+		}
+		ok, err := path.Match(pattern[0], name[0])
+		if !ok || err != nil {
+			return false, err
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0, nil
+}
+
+// matchAny reports whether name matches any of patterns.
+func matchAny(patterns []string, name string) (bool, error) {
+	for _, p := range patterns {
+		ok, err := MatchGlob(p, name)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,60 @@
+package initcore
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFileName is the per-directory file listing DestName patterns that
+// must never be written into that directory.
+const IgnoreFileName = ".initignore"
+
+// readIgnoreFile returns the glob patterns in dir's ignore file, one per
+// line. Blank lines and lines starting with # are skipped. A missing file
+// yields no patterns.
+func readIgnoreFile(dir string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, IgnoreFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", IgnoreFileName, err)
+	}
+
+	var patterns []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
+
+// partition splits files into those whose DestName matches none of
+// patterns and those that match at least one.
+func partition(files []File, patterns []string) (keep, matched []File, err error) {
+	if len(patterns) == 0 {
+		return files, nil, nil
+	}
+	for _, f := range files {
+		ok, err := matchAny(patterns, f.DestName)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			matched = append(matched, f)
+		} else {
+			keep = append(keep, f)
+		}
+	}
+	return keep, matched, nil
+}
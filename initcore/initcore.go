@@ -0,0 +1,876 @@
+// Package initcore writes a set of template files into a target directory,
+// refusing to overwrite existing files unless asked to. It is the engine
+// behind the init CLI and MCP server and can be embedded in other programs.
+package initcore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// File pairs template content with its destination filename.
+type File struct {
+	Content  []byte
+	DestName string
+
+	// Append, if set, appends the content to an existing destination
+	// instead of treating it as a collision. See Options.Append.
+	Append bool
+
+	// RequiresFlag, if set, makes the file optional: it is only written
+	// when the named flag is listed in Options.Enable.
+	RequiresFlag string
+
+	// Policy, if set, decides what happens when the destination already
+	// exists, overriding Options.Force and Options.Append for this file:
+	// one of PolicyFail, PolicySkip, PolicyOverwrite or PolicyAppend.
+	Policy string
+
+	// Compressed marks Content as gzip data. It is decompressed before
+	// anything else looks at it; see Decompress.
+	Compressed bool
+}
+
+// FileMode is the permission mode applied to every written file.
+const FileMode os.FileMode = 0644
+
+// DirMode is the permission mode of directories created for nested
+// destinations.
+const DirMode os.FileMode = 0755
+
+var (
+	// ErrFileExists is returned, as a *CollisionError, when a destination
+	// already exists and neither Options.Force nor the file's Policy allows
+	// replacing it.
+	ErrFileExists = errors.New("file already exists")
+
+	// ErrNotDirectory is returned when the target path is not a directory.
+	ErrNotDirectory = errors.New("not a directory")
+)
+
+// CollisionError reports a destination that already exists. It matches
+// ErrFileExists with errors.Is.
+type CollisionError struct {
+	Path string
+}
+
+func (e *CollisionError) Error() string {
+	return fmt.Sprintf("%v, refusing to overwrite: %s", ErrFileExists, e.Path)
+}
+
+func (e *CollisionError) Unwrap() error {
+	return ErrFileExists
+}
+
+// ResultSchemaVersion identifies the shape of Result as emitted in JSON.
+// Bump it whenever a field is removed, renamed or changes meaning.
+const ResultSchemaVersion = 1
+
+// Result holds the outcome of an init operation. Each file list is sorted
+// by path.
+type Result struct {
+	SchemaVersion    int      `json:"schema_version"`
+	Directory        string   `json:"directory"`
+	FilesCreated     []string `json:"files_created"`
+	FilesOverwritten []string `json:"files_overwritten,omitempty"`
+	FilesAppended    []string `json:"files_appended,omitempty"`
+	FilesExcluded    []string `json:"files_excluded,omitempty"`
+	FilesRemoved     []string `json:"files_removed,omitempty"`
+	FilesSkipped     []string `json:"files_skipped,omitempty"`
+	BytesWritten     int64    `json:"bytes_written"`
+	DryRun           bool     `json:"dry_run,omitempty"`
+
+	// GitInitialized is set only by callers that run git init afterwards.
+	GitInitialized *bool  `json:"git_initialized,omitempty"`
+	GitError       string `json:"git_error,omitempty"`
+
+	// Warnings lists non-fatal problems, such as an option that had no
+	// effect on this platform. Callers may add their own.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// sortFiles sorts each file list in r by path, so a Result does not depend
+// on the order files were processed in.
+func (r *Result) sortFiles() {
+	for _, list := range [][]string{
+		r.FilesCreated, r.FilesOverwritten, r.FilesAppended,
+		r.FilesExcluded, r.FilesRemoved, r.FilesSkipped,
+	} {
+		slices.Sort(list)
+	}
+}
+
+// File statuses reported in a FileEvent.
+const (
+	StatusCreated     = "created"
+	StatusOverwritten = "overwritten"
+	StatusAppended    = "appended"
+	StatusExcluded    = "excluded"
+	StatusSkipped     = "skipped"
+	StatusRemoved     = "removed"
+)
+
+// FileEvent reports the outcome for a single file as WriteFilesContext or
+// CleanContext processes it.
+type FileEvent struct {
+	File   string `json:"file"`
+	Status string `json:"status"`
+
+	// Bytes and SHA256 describe what was written (the whole file, for an
+	// append) or, for a removal, what was removed. Both are empty when
+	// nothing was touched.
+	Bytes  int64  `json:"bytes,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// Options controls a WriteFiles call.
+type Options struct {
+	// Files is the set of files to write, in order.
+	Files []File
+
+	// Force overwrites destinations that already exist instead of failing.
+	Force bool
+
+	// DryRun performs every check but writes nothing. The Result reports
+	// what would have been written.
+	DryRun bool
+
+	// FollowSymlinks writes through a destination that is a symlink, to
+	// wherever it points. By default such destinations fail with ErrSymlink.
+	FollowSymlinks bool
+
+	// Variables replaces each {{KEY}} placeholder in file content and in
+	// DestNames with its value. Placeholders without a matching key are
+	// left untouched. See SubstituteNames for the rules on names.
+	Variables map[string]string
+
+	// VariableSubdirs allows variable values substituted into a DestName to
+	// contain path separators, placing the file in a subdirectory.
+	VariableSubdirs bool
+
+	// EnsureTrailingNewline appends a single "\n" to content that does
+	// not already end with one.
+	EnsureTrailingNewline bool
+
+	// Incremental skips files whose content is unchanged since the hash
+	// recorded in the directory's LockFileName, and allows overwriting a
+	// file that still matches its recorded hash. The lock file is updated
+	// after a successful run.
+	Incremental bool
+
+	// Append makes every file behave as if File.Append were set: when the
+	// destination exists, the content is appended to it rather than failing
+	// or overwriting. Appending is idempotent; a file that already contains
+	// the block (or AppendMarker, if set) is skipped.
+	Append bool
+
+	// AppendMarker, if set, is written on its own line before each appended
+	// block and is what later runs look for to detect it.
+	AppendMarker string
+
+	// Enable lists the RequiresFlag names to turn on. Files whose
+	// RequiresFlag is not listed are left out.
+	Enable []string
+
+	// ModTime, if non-zero, is applied as the access and modification time
+	// of every written file. Zero leaves the time of the write.
+	ModTime time.Time
+
+	// Owner, if set, is applied to every written file and created
+	// directory. A failure to change ownership fails the call. Where
+	// ChownSupported is false it is ignored with a warning in the Result.
+	Owner *Owner
+
+	// Retries is how many times a write failing with a transient error
+	// (EINTR, EAGAIN) is retried, with exponential backoff. Other errors
+	// fail immediately.
+	Retries int
+
+	// RelativePaths reports paths in the Result and in FileEvents relative
+	// to the target directory instead of as absolute paths.
+	RelativePaths bool
+
+	// OnFile, if set, is called once per file as soon as its outcome is
+	// known: after it is written or removed, or when it is excluded or
+	// skipped.
+	OnFile func(FileEvent)
+
+	// Logger receives debug logs about individual file operations. A nil
+	// Logger discards them.
+	Logger *slog.Logger
+
+	// KeepNewerThan, if non-zero, protects recent edits: a destination
+	// that would be overwritten is skipped instead when its modification
+	// time is after KeepNewerThan.
+	KeepNewerThan time.Time
+
+	// WaitForDirectory, if positive, is how long WriteFilesContext waits
+	// for a missing target directory to appear, polling every
+	// directoryPollInterval, before failing. The wait ends early if the
+	// context is cancelled.
+	WaitForDirectory time.Duration
+
+	// RequireEmpty refuses to write into a directory that already has
+	// entries, other than those matching EmptyIgnore, failing with
+	// ErrNotEmpty before anything is written.
+	RequireEmpty bool
+
+	// EmptyIgnore lists glob patterns for entries that RequireEmpty
+	// tolerates. Nil means DefaultEmptyIgnore.
+	EmptyIgnore []string
+
+	// CleanDir removes every existing file that matches the file set
+	// before writing, by destination name or by content (see staleFiles),
+	// so copies left under old names go too. Removed files are listed in
+	// Result.FilesRemoved and restored if the run fails or is cancelled.
+	CleanDir bool
+
+	// Manifest, if set, names a file in the target directory to which a
+	// successful run writes a Manifest, replacing any earlier one. It is
+	// never treated as a collision, and Verify and CleanDir ignore it.
+	Manifest string
+
+	// ToolVersion is recorded in the Manifest.
+	ToolVersion string
+
+	// TemplateVersion is the version of the template set in Files. It is
+	// recorded in the Manifest, and Verify and Incremental runs warn when
+	// the directory's manifest records a different one.
+	TemplateVersion string
+
+	// FS performs the reads, writes, directory creation, removals and
+	// rollback of destinations; see FS for what it does not cover. A nil
+	// FS uses the real filesystem. Tests can supply one that fails on
+	// demand.
+	FS FS
+
+	// Exclude lists glob patterns (see MatchGlob) matched against each
+	// DestName. Matching files are skipped. Patterns from an IgnoreFileName
+	// file in the target directory are applied as well.
+	Exclude []string
+}
+
+// Render returns f's content as it is written to disk: variables
+// substituted and, if requested, newline-terminated.
+func (opts Options) Render(f File) []byte {
+	content := Substitute(f.Content, opts.Variables)
+	if opts.EnsureTrailingNewline && len(content) > 0 && content[len(content)-1] != '\n' {
+		content = append(content[:len(content):len(content)], '\n')
+	}
+	return content
+}
+
+// enabledFiles returns opts.Files without the optional files whose
+// RequiresFlag is not in opts.Enable.
+func (opts Options) enabledFiles() []File {
+	return slices.DeleteFunc(slices.Clone(opts.Files), func(f File) bool {
+		return f.RequiresFlag != "" && !slices.Contains(opts.Enable, f.RequiresFlag)
+	})
+}
+
+// selectFiles returns the files to act on in dir: the enabled files,
+// decompressed and with variables substituted into their names, minus
+// those matching opts.Exclude or dir's ignore file.
+func (opts Options) selectFiles(dir string) (keep, excluded, ignored []File, err error) {
+	if err := checkPolicies(opts.Files); err != nil {
+		return nil, nil, nil, err
+	}
+	enabled, err := Decompress(opts.enabledFiles())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	enabled, err = SubstituteNames(enabled, opts.Variables, opts.VariableSubdirs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	keep, excluded, err = partition(enabled, opts.Exclude)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	patterns, err := readIgnoreFile(dir)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	keep, ignored, err = partition(keep, patterns)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s: %w", IgnoreFileName, err)
+	}
+
+	return keep, excluded, ignored, nil
+}
+
+// WriteFiles writes opts.Files into dir. It is shorthand for
+// WriteFilesContext with context.Background.
+func WriteFiles(dir string, opts Options) (*Result, error) {
+	return WriteFilesContext(context.Background(), dir, opts)
+}
+
+// WriteFilesContext writes opts.Files into dir. If any step fails, or ctx
+// (checked between writes) is cancelled, every change made by this call is
+// rolled back (new files removed, overwritten and removed files restored)
+// before the error is returned. Unless opts.DryRun is set, the directory's RunLockFileName is
+// held for the duration, and ErrInProgress is returned if another run
+// already holds it.
+func WriteFilesContext(ctx context.Context, dir string, opts Options) (*Result, error) {
+	if err := waitForDirectory(ctx, dir, opts.WaitForDirectory); err != nil {
+		return nil, err
+	}
+	if err := checkDirectory(dir); err != nil {
+		return nil, err
+	}
+	if err := opts.checkEmpty(dir); err != nil {
+		return nil, err
+	}
+	if opts.Manifest != "" {
+		if err := checkDestName(opts.Manifest); err != nil {
+			return nil, fmt.Errorf("manifest: %w", err)
+		}
+	}
+
+	var warnings []string
+	if !opts.DryRun {
+		release, reclaimed, err := acquireRunLock(dir)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		if reclaimed {
+			warnings = append(warnings, fmt.Sprintf("took over a stale %s older than %s", RunLockFileName, StaleRunLock))
+		}
+	}
+
+	files, excluded, ignored, err := opts.selectFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		SchemaVersion: ResultSchemaVersion,
+		Directory:     dir,
+		DryRun:        opts.DryRun,
+		Warnings:      warnings,
+	}
+	for _, f := range excluded {
+		path := opts.reportPath(dir, filepath.Join(dir, f.DestName))
+		result.FilesExcluded = append(result.FilesExcluded, path)
+		opts.notify(FileEvent{File: path, Status: StatusExcluded})
+	}
+	for _, f := range ignored {
+		path := opts.reportPath(dir, filepath.Join(dir, f.DestName))
+		result.FilesSkipped = append(result.FilesSkipped, path)
+		opts.notify(FileEvent{File: path, Status: StatusSkipped})
+	}
+
+	var lock map[string]string
+	if opts.Incremental {
+		if lock, err = readLock(dir); err != nil {
+			return nil, err
+		}
+		warning, err := opts.templateVersionWarning(dir)
+		if err != nil {
+			return nil, err
+		}
+		if warning != "" {
+			result.Warnings = append(result.Warnings, warning)
+		}
+	}
+
+	fsys := opts.fs()
+	var done []change
+
+	// fail undoes every change made so far, including CleanDir removals,
+	// and returns err together with any rollback error.
+	fail := func(err error) (*Result, error) {
+		if len(done) == 0 {
+			return nil, err
+		}
+		return nil, errors.Join(err, rollback(fsys, done))
+	}
+
+	// hashes records what each file holds on disk afterwards, for the
+	// manifest.
+	hashes := make(map[string]string)
+
+	if opts.Owner != nil && !ChownSupported {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("changing file ownership is not supported on this platform; owner %s ignored", opts.Owner))
+		opts.Owner = nil
+	}
+
+	// removed holds the paths CleanDir took out of the way. In a dry run
+	// they are still on disk, so the loop below must treat them as gone.
+	removed := make(map[string]bool)
+	if opts.CleanDir {
+		stale, err := opts.staleFiles(dir, files)
+		if err != nil {
+			return nil, err
+		}
+		// Refuse before removing anything if a destination would fail
+		// the checks in the loop below.
+		if err := opts.checkDestinations(dir, files, stale, lock); err != nil {
+			return nil, err
+		}
+		for _, path := range stale {
+			original, err := fsys.ReadFile(path)
+			if err != nil {
+				return fail(fmt.Errorf("reading %s: %w", path, err))
+			}
+			if !opts.DryRun {
+				if err := fsys.Remove(path); err != nil {
+					return fail(fmt.Errorf("removing %s: %w", path, err))
+				}
+				done = append(done, change{path: path, existed: true, original: original})
+			}
+			removed[path] = true
+			shown := opts.reportPath(dir, path)
+			result.FilesRemoved = append(result.FilesRemoved, shown)
+			opts.notify(FileEvent{File: shown, Status: StatusRemoved, Bytes: int64(len(original)), SHA256: hashContent(original)})
+			opts.logger().Info("removed file", "path", path, "bytes", len(original), "dry_run", opts.DryRun)
+		}
+	}
+
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return fail(fmt.Errorf("cancelled after writing %d of %d files: %w", len(done), len(files), err))
+		}
+
+		destPath, err := resolvePath(dir, f.DestName)
+		if err != nil {
+			return fail(err)
+		}
+		if err := checkSymlink(destPath, opts.FollowSymlinks); err != nil {
+			return fail(err)
+		}
+		shown := opts.reportPath(dir, destPath)
+		content := opts.Render(f)
+
+		original, err := fsys.ReadFile(destPath)
+		exists := err == nil && !removed[destPath]
+
+		if opts.skipsIncremental(f, content, exists, lock) {
+			hashes[f.DestName] = hashContent(original)
+			result.FilesSkipped = append(result.FilesSkipped, shown)
+			opts.notify(FileEvent{File: shown, Status: StatusSkipped})
+			continue
+		}
+
+		if exists && opts.keeps(f) {
+			hashes[f.DestName] = hashContent(original)
+			result.FilesSkipped = append(result.FilesSkipped, shown)
+			opts.notify(FileEvent{File: shown, Status: StatusSkipped})
+			continue
+		}
+		appending := exists && opts.appends(f)
+		if exists && opts.collides(f, original, lock) {
+			return fail(&CollisionError{Path: destPath})
+		}
+		if exists && !appending && opts.newer(fsys, destPath) {
+			hashes[f.DestName] = hashContent(original)
+			result.FilesSkipped = append(result.FilesSkipped, shown)
+			opts.notify(FileEvent{File: shown, Status: StatusSkipped})
+			continue
+		}
+
+		if !exists && opts.appends(f) {
+			// Start with the marker so later runs recognise the block.
+			content = opts.appendBlock(content)
+		}
+		size := int64(len(content))
+		if appending {
+			if opts.appended(original, content) {
+				hashes[f.DestName] = hashContent(original)
+				result.FilesSkipped = append(result.FilesSkipped, shown)
+				opts.notify(FileEvent{File: shown, Status: StatusSkipped})
+				continue
+			}
+			content = opts.appendTo(original, content)
+			size = int64(len(content) - len(original))
+		}
+
+		if !opts.DryRun {
+			created, err := mkdirParents(fsys, dir, destPath)
+			for _, d := range created {
+				done = append(done, change{path: d})
+			}
+			if err != nil {
+				return fail(err)
+			}
+			if err := opts.writeFile(destPath, content); err != nil {
+				return fail(fmt.Errorf("writing %s: %w", f.DestName, err))
+			}
+			done = append(done, change{path: destPath, existed: exists, original: original})
+
+			if opts.Owner != nil {
+				for _, path := range append(created, destPath) {
+					if err := fsys.Chown(path, opts.Owner.UID, opts.Owner.GID); err != nil {
+						return fail(fmt.Errorf("setting owner of %s to %s: %w", path, opts.Owner, err))
+					}
+				}
+				opts.logger().Debug("set owner", "path", destPath, "owner", opts.Owner.String())
+			}
+
+			if !opts.ModTime.IsZero() {
+				if err := fsys.Chtimes(destPath, opts.ModTime, opts.ModTime); err != nil {
+					return fail(fmt.Errorf("setting mtime of %s: %w", f.DestName, err))
+				}
+				opts.logger().Debug("set mtime", "path", destPath, "mtime", opts.ModTime.Format(time.RFC3339))
+			}
+		}
+
+		event := FileEvent{File: shown, Bytes: size, SHA256: hashContent(content)}
+		switch {
+		case appending:
+			result.FilesAppended = append(result.FilesAppended, shown)
+			event.Status = StatusAppended
+		case exists:
+			result.FilesOverwritten = append(result.FilesOverwritten, shown)
+			event.Status = StatusOverwritten
+		default:
+			result.FilesCreated = append(result.FilesCreated, shown)
+			event.Status = StatusCreated
+		}
+		opts.notify(event)
+		hashes[f.DestName] = event.SHA256
+		result.BytesWritten += size
+		if opts.Incremental {
+			lock[f.DestName] = hashContent(content)
+		}
+	}
+
+	result.sortFiles()
+	if opts.Manifest != "" && !opts.DryRun {
+		if err := opts.writeManifest(dir, result, hashes); err != nil {
+			return fail(err)
+		}
+	}
+
+	// The lock comes last: once it records the files, later incremental
+	// runs skip them, so nothing after it may fail and roll them back.
+	if opts.Incremental && !opts.DryRun {
+		if err := writeLock(dir, lock); err != nil {
+			return fail(err)
+		}
+	}
+	return result, nil
+}
+
+// skipsIncremental reports whether an incremental run leaves f's
+// destination alone: it still exists, and lock records that an earlier run
+// wrote the same rendered content there. A destination that has been
+// deleted is written again.
+func (opts Options) skipsIncremental(f File, content []byte, exists bool, lock map[string]string) bool {
+	if !opts.Incremental || !exists {
+		return false
+	}
+	recorded, ok := lock[f.DestName]
+	return ok && recorded == hashContent(content)
+}
+
+// collides reports whether f's existing destination, holding original,
+// stops the write: nothing allows skipping, replacing or appending to it.
+// An incremental run may replace a file whose content still matches the
+// hash recorded in lock, or skip it when the template is unchanged.
+func (opts Options) collides(f File, original []byte, lock map[string]string) bool {
+	if opts.keeps(f) || opts.appends(f) || opts.overwrites(f) {
+		return false
+	}
+	if opts.Incremental {
+		recorded, ok := lock[f.DestName]
+		if ok && (recorded == hashContent(opts.Render(f)) || recorded == hashContent(original)) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkDestinations runs the per-destination checks of WriteFilesContext
+// (containment, symlinks and collisions) for every file up front. removed
+// lists the paths that will be gone by the time the files are written.
+func (opts Options) checkDestinations(dir string, files []File, removed []string, lock map[string]string) error {
+	for _, f := range files {
+		destPath, err := resolvePath(dir, f.DestName)
+		if err != nil {
+			return err
+		}
+		if err := checkSymlink(destPath, opts.FollowSymlinks); err != nil {
+			return err
+		}
+		if slices.Contains(removed, destPath) {
+			continue
+		}
+		original, err := opts.fs().ReadFile(destPath)
+		if err == nil && opts.collides(f, original, lock) {
+			return &CollisionError{Path: destPath}
+		}
+	}
+	return nil
+}
+
+// newer reports whether path was modified after opts.KeepNewerThan, and
+// so must not be overwritten.
+func (opts Options) newer(fsys FS, path string) bool {
+	if opts.KeepNewerThan.IsZero() {
+		return false
+	}
+	info, err := fsys.Stat(path)
+	if err != nil || !info.ModTime().After(opts.KeepNewerThan) {
+		return false
+	}
+	opts.logger().Debug("keeping newer file", "path", path, "mtime", info.ModTime().Format(time.RFC3339), "reference", opts.KeepNewerThan.Format(time.RFC3339))
+	return true
+}
+
+// reportPath returns path as it appears in a Result: relative to dir when
+// opts.RelativePaths is set, unchanged otherwise.
+func (opts Options) reportPath(dir, path string) string {
+	if !opts.RelativePaths {
+		return path
+	}
+	if rel, err := filepath.Rel(dir, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// notify reports ev to opts.OnFile, if set.
+func (opts Options) notify(ev FileEvent) {
+	if opts.OnFile != nil {
+		opts.OnFile(ev)
+	}
+}
+
+// mkdirParents creates the missing directories between dir and destPath's
+// parent and returns them, outermost first.
+func mkdirParents(fsys FS, dir, destPath string) ([]string, error) {
+	var missing []string
+	for p := filepath.Dir(destPath); p != filepath.Clean(dir); p = filepath.Dir(p) {
+		if _, err := fsys.Stat(p); err == nil {
+			break
+		}
+		missing = append(missing, p)
+	}
+
+	var created []string
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := fsys.Mkdir(missing[i], DirMode); err != nil {
+			return created, fmt.Errorf("creating directory: %w", err)
+		}
+		created = append(created, missing[i])
+	}
+	return created, nil
+}
+
+// change records one file or directory created by WriteFilesContext so it
+// can be undone.
+type change struct {
+	path     string
+	existed  bool
+	original []byte
+}
+
+// rollback undoes changes newest first: new files and directories are
+// removed and overwritten files get their original content back.
+func rollback(fsys FS, changes []change) error {
+	var errs []error
+	for i := len(changes) - 1; i >= 0; i-- {
+		c := changes[i]
+		var err error
+		if c.existed {
+			err = fsys.WriteFile(c.path, c.original, FileMode)
+		} else {
+			err = fsys.Remove(c.path)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rolling back %s: %w", c.path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// directoryPollInterval is how often waitForDirectory checks for the
+// directory.
+const directoryPollInterval = 100 * time.Millisecond
+
+// waitForDirectory returns once dir exists, or when timeout has passed
+// (leaving checkDirectory to report it missing). It returns ctx's error if
+// ctx is cancelled first.
+func waitForDirectory(ctx context.Context, dir string, timeout time.Duration) error {
+	if timeout <= 0 {
+		return nil
+	}
+	deadline := time.Now().Add(timeout)
+	ticker := time.NewTicker(directoryPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := os.Stat(dir); !errors.Is(err, fs.ErrNotExist) || time.Now().After(deadline) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for %s: %w", dir, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkDirectory returns an error unless dir exists and is a directory.
+// Windows drive-relative paths such as C:foo are rejected because their
+// meaning depends on the process's per-drive working directory.
+func checkDirectory(dir string) error {
+	if vol := filepath.VolumeName(dir); vol != "" && !filepath.IsAbs(dir) {
+		return fmt.Errorf("drive-relative directory %q is not supported; use a full path such as %s\\%s", dir, vol, dir[len(vol):])
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("checking directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %s", ErrNotDirectory, dir)
+	}
+	return nil
+}
+
+// Substitute replaces each {{KEY}} in content with vars[KEY]. It returns
+// content unchanged when vars is empty.
+func Substitute(content []byte, vars map[string]string) []byte {
+	if len(vars) == 0 {
+		return content
+	}
+
+	pairs := make([]string, 0, 2*len(vars))
+	for _, k := range slices.Sorted(maps.Keys(vars)) {
+		pairs = append(pairs, "{{"+k+"}}", vars[k])
+	}
+	return []byte(strings.NewReplacer(pairs...).Replace(string(content)))
+}
+
+// SubstituteNames returns a copy of files with each {{KEY}} placeholder in
+// a DestName replaced by its value in vars, as Substitute does for content.
+// A value containing a path separator is rejected with ErrUnsafePath
+// unless subdirs is set, and no two files may end up with the same
+// destination.
+func SubstituteNames(files []File, vars map[string]string, subdirs bool) ([]File, error) {
+	if len(vars) == 0 {
+		return files, nil
+	}
+
+	substituted := make([]File, len(files))
+	seen := make(map[string]string, len(files))
+
+	for i, f := range files {
+		for _, k := range slices.Sorted(maps.Keys(vars)) {
+			if !subdirs && strings.Contains(f.DestName, "{{"+k+"}}") && strings.ContainsAny(vars[k], `/\`) {
+				return nil, fmt.Errorf("%w: variable %s would add a path separator to %q", ErrUnsafePath, k, f.DestName)
+			}
+		}
+		f.DestName = string(Substitute([]byte(f.DestName), vars))
+		if prev, ok := seen[f.DestName]; ok {
+			return nil, fmt.Errorf("name collision: %s and %s both become %s", prev, files[i].DestName, f.DestName)
+		}
+		seen[f.DestName] = files[i].DestName
+		substituted[i] = f
+	}
+
+	return substituted, nil
+}
+
+// ApplyRenames returns a copy of files with DestNames remapped according to
+// renames (old name to new name). Every old name must match a file in the set
+// and no two files may end up with the same destination.
+func ApplyRenames(files []File, renames map[string]string) ([]File, error) {
+	if len(renames) == 0 {
+		return files, nil
+	}
+
+	for oldName := range renames {
+		if !slices.ContainsFunc(files, func(f File) bool { return f.DestName == oldName }) {
+			return nil, fmt.Errorf("rename source not found: %s", oldName)
+		}
+	}
+
+	renamed := make([]File, len(files))
+	seen := make(map[string]string, len(files))
+
+	for i, f := range files {
+		if newName, ok := renames[f.DestName]; ok {
+			f.DestName = newName
+		}
+		if prev, ok := seen[f.DestName]; ok {
+			return nil, fmt.Errorf("rename collision: %s and %s both map to %s", prev, files[i].DestName, f.DestName)
+		}
+		seen[f.DestName] = files[i].DestName
+		renamed[i] = f
+	}
+
+	return renamed, nil
+}
+
+// ExpandEnv returns a copy of files with $VAR and ${VAR} references in each
+// DestName replaced by the value of the environment variable. Unset variables
+// expand to the empty string; their names are returned, sorted and without
+// duplicates, so callers can warn about them.
+func ExpandEnv(files []File) ([]File, []string) {
+	return ExpandEnvFunc(files, os.LookupEnv)
+}
+
+// ExpandEnvFunc is ExpandEnv with variables looked up by lookup instead of
+// in the environment. lookup reports false for a variable that is unset.
+func ExpandEnvFunc(files []File, lookup func(string) (string, bool)) ([]File, []string) {
+	var unset []string
+	expanded := make([]File, len(files))
+	for i, f := range files {
+		f.DestName = os.Expand(f.DestName, func(name string) string {
+			value, ok := lookup(name)
+			if !ok && !slices.Contains(unset, name) {
+				unset = append(unset, name)
+			}
+			return value
+		})
+		expanded[i] = f
+	}
+	slices.Sort(unset)
+	return expanded, unset
+}
+
+// ApplyPrefix returns a copy of files with prefix joined in front of every
+// DestName, placing the whole set under a subdirectory. An empty prefix
+// returns files unchanged.
+func ApplyPrefix(files []File, prefix string) []File {
+	if prefix == "" {
+		return files
+	}
+	prefixed := make([]File, len(files))
+	for i, f := range files {
+		f.DestName = filepath.Join(prefix, f.DestName)
+		prefixed[i] = f
+	}
+	return prefixed
+}
+
+// CheckTotalSize returns an error if the combined content size of files
+// exceeds limit. A limit of zero or less disables the check.
+func CheckTotalSize(files []File, limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+
+	var total int64
+	for _, f := range files {
+		total += int64(len(f.Content))
+	}
+	if total > limit {
+		return fmt.Errorf("total size %d bytes exceeds limit of %d bytes", total, limit)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package initcore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LockFileName is the file in the target directory where incremental runs
+// record the hash of each file they wrote.
+const LockFileName = ".init.lock"
+
+// lockFile is the JSON content of LockFileName.
+type lockFile struct {
+	Version int               `json:"version"`
+	Files   map[string]string `json:"files"`
+}
+
+// readLock returns the DestName to SHA-256 map recorded in dir's lock file,
+// or an empty map if there is none.
+func readLock(dir string) (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, LockFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", LockFileName, err)
+	}
+
+	var lock lockFile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", LockFileName, err)
+	}
+	if lock.Files == nil {
+		lock.Files = map[string]string{}
+	}
+	return lock.Files, nil
+}
+
+// writeLock records hashes in dir's lock file.
+func writeLock(dir string, hashes map[string]string) error {
+	data, err := json.MarshalIndent(lockFile{Version: 1, Files: hashes}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, LockFileName), append(data, '\n'), FileMode); err != nil {
+		return fmt.Errorf("writing %s: %w", LockFileName, err)
+	}
+	return nil
+}
+
+// hashContent returns the hex SHA-256 of content.
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
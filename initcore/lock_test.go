@@ -0,0 +1,67 @@
+package initcore
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// lockTestFiles is a small file set for incremental runs.
+func lockTestFiles() []File {
+	return []File{
+		{Content: []byte("contributing\n"), DestName: "CONTRIBUTING.md"},
+		{Content: []byte("license\n"), DestName: "LICENSE"},
+	}
+}
+
+func TestIncrementalRecreatesDeletedFile(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{Files: lockTestFiles(), Incremental: true, RelativePaths: true}
+	if _, err := WriteFiles(dir, opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "LICENSE")); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := WriteFiles(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.FilesCreated, []string{"LICENSE"}) || !slices.Equal(result.FilesSkipped, []string{"CONTRIBUTING.md"}) {
+		t.Errorf("created %q, skipped %q; want LICENSE created and CONTRIBUTING.md skipped", result.FilesCreated, result.FilesSkipped)
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "LICENSE")); err != nil || string(got) != "license\n" {
+		t.Errorf("LICENSE = %q, %v; want it written again", got, err)
+	}
+}
+
+func TestIncrementalLockNotWrittenOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{
+		Files:       lockTestFiles(),
+		Incremental: true,
+		Manifest:    DefaultManifestName,
+		FS:          &failingFS{failWrite: filepath.Join(dir, DefaultManifestName)},
+	}
+	if _, err := WriteFiles(dir, opts); err == nil {
+		t.Fatal("want the manifest write to fail")
+	}
+	lock, err := readLock(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lock) != 0 {
+		t.Errorf("lock records %v after the run was rolled back", lock)
+	}
+
+	opts.FS = nil
+	result, err := WriteFiles(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.FilesCreated) != 2 {
+		t.Errorf("created %q, want both files written by the retry", result.FilesCreated)
+	}
+}
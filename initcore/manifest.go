@@ -0,0 +1,110 @@
+package initcore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DefaultManifestName is the conventional name for Options.Manifest.
+const DefaultManifestName = ".init-manifest.json"
+
+// ManifestVersion identifies the shape of Manifest as written to disk.
+const ManifestVersion = 1
+
+// Manifest is the JSON record a successful run leaves in the target
+// directory when Options.Manifest is set.
+type Manifest struct {
+	Version         int    `json:"version"`
+	ToolVersion     string `json:"tool_version,omitempty"`
+	TemplateVersion string `json:"template_version,omitempty"`
+
+	// Result is the run's outcome, as returned by WriteFilesContext.
+	Result *Result `json:"result"`
+
+	// Files maps each DestName in the file set to the hex SHA-256 of its
+	// content on disk after the run. Excluded and ignored files are left
+	// out.
+	Files map[string]string `json:"files"`
+}
+
+// isStateFile reports whether name, an entry in the target directory, is
+// one of the files init keeps there for itself rather than a template.
+func (opts Options) isStateFile(name string) bool {
+	switch name {
+	case IgnoreFileName, LockFileName, RunLockFileName, DefaultManifestName:
+		return true
+	}
+	return opts.Manifest != "" && filepath.Clean(opts.Manifest) == name
+}
+
+// writeManifest writes the manifest for result to opts.Manifest in dir,
+// replacing any earlier one.
+func (opts Options) writeManifest(dir string, result *Result, hashes map[string]string) error {
+	path, err := resolvePath(dir, opts.Manifest)
+	if err != nil {
+		return fmt.Errorf("manifest: %w", err)
+	}
+	if err := checkSymlink(path, opts.FollowSymlinks); err != nil {
+		return fmt.Errorf("manifest: %w", err)
+	}
+
+	if _, err := mkdirParents(opts.fs(), dir, path); err != nil {
+		return fmt.Errorf("manifest: %w", err)
+	}
+
+	data, err := json.MarshalIndent(Manifest{
+		Version:         ManifestVersion,
+		ToolVersion:     opts.ToolVersion,
+		TemplateVersion: opts.TemplateVersion,
+		Result:          result,
+		Files:           hashes,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := opts.fs().WriteFile(path, append(data, '\n'), FileMode); err != nil {
+		return fmt.Errorf("writing %s: %w", opts.Manifest, err)
+	}
+	opts.logger().Debug("wrote manifest", "path", path, "files", len(hashes))
+	return nil
+}
+
+// ReadManifest reads the manifest called name in dir. It returns nil, and
+// no error, if there is none.
+func ReadManifest(dir, name string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", name, err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	return &m, nil
+}
+
+// templateVersionWarning returns a warning if dir's manifest (opts.Manifest,
+// or DefaultManifestName) records a template set version other than
+// opts.TemplateVersion, or "" if they match or either is unknown.
+func (opts Options) templateVersionWarning(dir string) (string, error) {
+	if opts.TemplateVersion == "" {
+		return "", nil
+	}
+	name := opts.Manifest
+	if name == "" {
+		name = DefaultManifestName
+	}
+	m, err := ReadManifest(dir, name)
+	if err != nil || m == nil || m.TemplateVersion == "" || m.TemplateVersion == opts.TemplateVersion {
+		return "", err
+	}
+	return fmt.Sprintf("%s was initialized from template set version %s; this is version %s", dir, m.TemplateVersion, opts.TemplateVersion), nil
+}
@@ -0,0 +1,34 @@
+package initcore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Owner is the numeric user and group that written files are given.
+type Owner struct {
+	UID int
+	GID int
+}
+
+// ParseOwner parses an owner written as "uid:gid", e.g. "1000:1000".
+func ParseOwner(s string) (*Owner, error) {
+	uidText, gidText, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid owner %q: want uid:gid", s)
+	}
+	uid, err := strconv.Atoi(uidText)
+	if err != nil || uid < 0 {
+		return nil, fmt.Errorf("invalid owner %q: uid must be a non-negative integer", s)
+	}
+	gid, err := strconv.Atoi(gidText)
+	if err != nil || gid < 0 {
+		return nil, fmt.Errorf("invalid owner %q: gid must be a non-negative integer", s)
+	}
+	return &Owner{UID: uid, GID: gid}, nil
+}
+
+func (o Owner) String() string {
+	return fmt.Sprintf("%d:%d", o.UID, o.GID)
+}
@@ -0,0 +1,100 @@
+package initcore
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrUnsafePath is returned when a DestName would resolve outside the
+	// target directory.
+	ErrUnsafePath = errors.New("destination escapes target directory")
+
+	// ErrSymlink is returned when a destination is itself a symlink and
+	// Options.FollowSymlinks is not set.
+	ErrSymlink = errors.New("destination is a symlink")
+)
+
+// resolvePath joins destName onto dir and verifies the result stays inside
+// dir, both lexically and after resolving any symlinks in the destination's
+// existing parent directories.
+func resolvePath(dir, destName string) (string, error) {
+	if err := checkDestName(destName); err != nil {
+		return "", err
+	}
+
+	destPath := filepath.Join(dir, destName)
+	if !within(filepath.Clean(dir), destPath) {
+		return "", fmt.Errorf("%w: %q", ErrUnsafePath, destName)
+	}
+
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("resolving directory: %w", err)
+	}
+
+	// Missing parents will be created, so check the nearest ancestor that
+	// exists: a symlink there would redirect everything below it.
+	parent := filepath.Dir(destPath)
+	for {
+		realParent, err := filepath.EvalSymlinks(parent)
+		if errors.Is(err, fs.ErrNotExist) && parent != filepath.Clean(dir) {
+			parent = filepath.Dir(parent)
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("resolving %s: %w", destName, err)
+		}
+		if !within(realDir, realParent) {
+			return "", fmt.Errorf("%w via symlink: %q", ErrUnsafePath, destName)
+		}
+		return destPath, nil
+	}
+}
+
+// checkSymlink returns ErrSymlink if destPath is a symlink, even a dangling
+// one, unless follow is set. Writing through such a link could replace a
+// file outside the target directory.
+func checkSymlink(destPath string, follow bool) error {
+	if follow {
+		return nil
+	}
+	info, err := os.Lstat(destPath)
+	if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+		return nil
+	}
+	target, _ := os.Readlink(destPath)
+	return fmt.Errorf("%w, refusing to write through it: %s -> %s", ErrSymlink, destPath, target)
+}
+
+// checkDestName rejects destination names that are not plain relative
+// paths. On Windows this includes drive-relative names such as C:foo, UNC
+// names such as \\server\share\foo, rooted names such as \foo, and reserved
+// device names such as NUL.
+func checkDestName(destName string) error {
+	switch {
+	case destName == "":
+		return fmt.Errorf("%w: empty destination name", ErrUnsafePath)
+	case filepath.VolumeName(destName) != "":
+		return fmt.Errorf("%w: %q has a drive or UNC prefix", ErrUnsafePath, destName)
+	case filepath.IsAbs(destName):
+		return fmt.Errorf("%w: %q is absolute", ErrUnsafePath, destName)
+	case !filepath.IsLocal(destName):
+		return fmt.Errorf("%w: %q", ErrUnsafePath, destName)
+	}
+	return nil
+}
+
+// within reports whether path is root or lies beneath it. Both arguments
+// must be clean.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))
+}
@@ -0,0 +1,154 @@
+package initcore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCheckDestName(t *testing.T) {
+	for _, name := range []string{"LICENSE", ".github/workflows/ci.yml", "docs/../README.md", "./README.md"} {
+		if err := checkDestName(name); err != nil {
+			t.Errorf("checkDestName(%q) = %v, want nil", name, err)
+		}
+	}
+	for _, name := range []string{"", "..", "../x", "docs/../../x", "/etc/passwd", string(filepath.Separator) + "x"} {
+		if err := checkDestName(name); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("checkDestName(%q) = %v, want ErrUnsafePath", name, err)
+		}
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	dir := t.TempDir()
+	got, err := resolvePath(dir, "docs/guide.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "docs", "guide.md"); got != want {
+		t.Errorf("resolvePath = %q, want %q", got, want)
+	}
+
+	for _, name := range []string{"..", "../x", "a/../../x", filepath.Join(t.TempDir(), "x")} {
+		if _, err := resolvePath(dir, name); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("resolvePath(%q) = %v, want ErrUnsafePath", name, err)
+		}
+	}
+}
+
+func TestResolvePathSymlinkEscape(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "real"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "real"), filepath.Join(dir, "inside")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Missing parents below the link are still checked against it.
+	for _, name := range []string{"link/x", "link/missing/x"} {
+		if _, err := resolvePath(dir, name); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("resolvePath(%q) = %v, want ErrUnsafePath", name, err)
+		}
+	}
+	if _, err := resolvePath(dir, "inside/x"); err != nil {
+		t.Errorf("resolvePath through a link within the directory: %v", err)
+	}
+
+	_, err := WriteFiles(dir, Options{Files: []File{{Content: []byte("x\n"), DestName: "link/x"}}})
+	if !errors.Is(err, ErrUnsafePath) {
+		t.Fatalf("WriteFiles = %v, want ErrUnsafePath", err)
+	}
+	if _, err := os.Lstat(filepath.Join(outside, "x")); err == nil {
+		t.Error("WriteFiles wrote outside the target directory")
+	}
+}
+
+func TestCheckSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(t.TempDir(), "target")
+	if err := os.WriteFile(target, []byte("outside\n"), FileMode); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"dangling": filepath.Join(t.TempDir(), "missing"),
+		"existing": target,
+	}
+	for name, to := range links {
+		if err := os.Symlink(to, filepath.Join(dir, name)); err != nil {
+			t.Skipf("symlinks unavailable: %v", err)
+		}
+	}
+
+	for name := range links {
+		if err := checkSymlink(filepath.Join(dir, name), false); !errors.Is(err, ErrSymlink) {
+			t.Errorf("checkSymlink(%s) = %v, want ErrSymlink", name, err)
+		}
+		_, err := WriteFiles(dir, Options{
+			Files: []File{{Content: []byte("new\n"), DestName: name}},
+			Force: true,
+		})
+		if !errors.Is(err, ErrSymlink) {
+			t.Errorf("WriteFiles through %s link = %v, want ErrSymlink", name, err)
+		}
+	}
+	if got, _ := os.ReadFile(target); string(got) != "outside\n" {
+		t.Errorf("link target = %q, want it unchanged", got)
+	}
+	if err := checkSymlink(filepath.Join(dir, "plain"), false); err != nil {
+		t.Errorf("checkSymlink on a missing file = %v, want nil", err)
+	}
+}
+
+func TestFollowSymlinksWritesThrough(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(t.TempDir(), "target")
+	if err := os.WriteFile(target, []byte("outside\n"), FileMode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(dir, "LICENSE")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	_, err := WriteFiles(dir, Options{
+		Files:          []File{{Content: []byte("license\n"), DestName: "LICENSE"}},
+		Force:          true,
+		FollowSymlinks: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(target); string(got) != "license\n" {
+		t.Errorf("link target = %q, want the new content", got)
+	}
+	if info, err := os.Lstat(filepath.Join(dir, "LICENSE")); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("LICENSE is no longer a symlink: %v", err)
+	}
+}
+
+func TestCheckDestNameWindows(t *testing.T) {
+	if runtime.GOOS != "windows" {
+		t.Skip("Windows path forms")
+	}
+	for _, name := range []string{
+		`C:foo`,                  // drive-relative
+		`C:\foo`,                 // drive-absolute
+		`\\server\share\foo`,     // UNC
+		`\\?\C:\foo`,             // device path
+		`\foo`,                   // rooted
+		`NUL`, `con`, `docs\AUX`, // reserved device names
+	} {
+		if err := checkDestName(name); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("checkDestName(%q) = %v, want ErrUnsafePath", name, err)
+		}
+	}
+	if err := checkDestName(`docs\guide.md`); err != nil {
+		t.Errorf(`checkDestName("docs\\guide.md") = %v, want nil`, err)
+	}
+}
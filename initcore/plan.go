@@ -0,0 +1,165 @@
+package initcore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Actions reported in an Operation.
+const (
+	ActionCreate    = "create"
+	ActionOverwrite = "overwrite"
+	ActionAppend    = "append"
+	ActionSkip      = "skip"
+	ActionRemove    = "remove"
+)
+
+// Operation describes a single planned file write. The CLI prints a plan as
+// a bare JSON array with no schema version, so fields are only ever added
+// here, never removed, renamed or given a new meaning.
+type Operation struct {
+	Action   string `json:"action"`
+	Path     string `json:"path"`
+	Size     int    `json:"size"`
+	Mode     string `json:"mode"`
+	Conflict bool   `json:"conflict"`
+}
+
+// Plan reports the operations WriteFiles would perform in dir without
+// touching the filesystem. Destinations that already exist are flagged as
+// conflicts rather than treated as errors, unless opts.Force or the file's
+// Policy allows replacing them, in which case they are planned as
+// overwrites; files with PolicySkip are planned as skips. Files that append (see
+// Options.Append) are planned as appends, or skips if the block is already
+// present. With opts.CleanDir, the files it would remove are listed first
+// and no longer count as existing. Files matched by the directory's ignore
+// file are listed last as skips. With opts.Incremental, the directory's
+// lock file decides skips and overwrites as it does for WriteFilesContext.
+// Paths are reported as opts.RelativePaths asks.
+func Plan(dir string, opts Options) ([]Operation, error) {
+	if err := checkDirectory(dir); err != nil {
+		return nil, err
+	}
+	if err := opts.checkEmpty(dir); err != nil {
+		return nil, err
+	}
+
+	files, _, ignored, err := opts.selectFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var lock map[string]string
+	if opts.Incremental {
+		if lock, err = readLock(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	ops := make([]Operation, 0, len(files)+len(ignored))
+	removed := make(map[string]bool)
+	if opts.CleanDir {
+		stale, err := opts.staleFiles(dir, files)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range stale {
+			removed[path] = true
+			ops = append(ops, Operation{Action: ActionRemove, Path: opts.reportPath(dir, path), Mode: fmt.Sprintf("%#o", FileMode.Perm())})
+		}
+	}
+	for _, f := range files {
+		destPath, err := resolvePath(dir, f.DestName)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkSymlink(destPath, opts.FollowSymlinks); err != nil {
+			return nil, err
+		}
+		existing, err := os.ReadFile(destPath)
+		exists := err == nil && !removed[destPath]
+		content := opts.Render(f)
+
+		action := ActionCreate
+		size := len(content)
+		switch {
+		case opts.skipsIncremental(f, content, exists, lock):
+			action, size = ActionSkip, 0
+		case exists && opts.keeps(f):
+			action, size = ActionSkip, 0
+		case exists && opts.appends(f) && opts.appended(existing, content):
+			action, size = ActionSkip, 0
+		case exists && opts.appends(f):
+			action, size = ActionAppend, len(opts.appendTo(existing, content))-len(existing)
+		case exists && !opts.collides(f, existing, lock) && opts.newer(opts.fs(), destPath):
+			action, size = ActionSkip, 0
+		case exists && !opts.collides(f, existing, lock):
+			action = ActionOverwrite
+		case !exists && opts.appends(f):
+			size = len(opts.appendBlock(content))
+		}
+
+		ops = append(ops, Operation{
+			Action:   action,
+			Path:     opts.reportPath(dir, destPath),
+			Size:     size,
+			Mode:     fmt.Sprintf("%#o", FileMode.Perm()),
+			Conflict: exists && opts.collides(f, existing, lock),
+		})
+	}
+	for _, f := range ignored {
+		ops = append(ops, Operation{
+			Action: ActionSkip,
+			Path:   opts.reportPath(dir, filepath.Join(dir, f.DestName)),
+			Size:   len(opts.Render(f)),
+			Mode:   fmt.Sprintf("%#o", FileMode.Perm()),
+		})
+	}
+	return ops, nil
+}
+
+// FileInfo describes a single template file without writing it.
+type FileInfo struct {
+	DestName string `json:"dest_name"`
+	Mode     string `json:"mode"`
+	Size     int    `json:"size"`
+	SHA256   string `json:"sha256"`
+
+	RequiresFlag string `json:"requires_flag,omitempty"`
+	Policy       string `json:"policy,omitempty"`
+}
+
+// Describe returns metadata for the file in files whose DestName is name.
+func Describe(files []File, name string) (*FileInfo, error) {
+	for _, f := range files {
+		if f.DestName == name {
+			info := describe(f)
+			return &info, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown file: %s", name)
+}
+
+// List returns metadata for every file in files, in order.
+func List(files []File) []FileInfo {
+	infos := make([]FileInfo, len(files))
+	for i, f := range files {
+		infos[i] = describe(f)
+	}
+	return infos
+}
+
+func describe(f File) FileInfo {
+	sum := sha256.Sum256(f.Content)
+	return FileInfo{
+		DestName:     f.DestName,
+		Mode:         fmt.Sprintf("%#o", FileMode.Perm()),
+		Size:         len(f.Content),
+		SHA256:       hex.EncodeToString(sum[:]),
+		RequiresFlag: f.RequiresFlag,
+		Policy:       f.Policy,
+	}
+}
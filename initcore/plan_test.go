@@ -0,0 +1,78 @@
+package initcore
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestPlanRelativePaths(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, IgnoreFileName), []byte("NOTES.md\n"), FileMode); err != nil {
+		t.Fatal(err)
+	}
+	ops, err := Plan(dir, Options{
+		Files: []File{
+			{Content: []byte("license\n"), DestName: "LICENSE"},
+			{Content: []byte("guide\n"), DestName: "docs/guide.md"},
+			{Content: []byte("notes\n"), DestName: "NOTES.md"},
+		},
+		RelativePaths: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, op := range ops {
+		got = append(got, op.Path)
+	}
+	want := []string{"LICENSE", filepath.Join("docs", "guide.md"), "NOTES.md"}
+	if !slices.Equal(got, want) {
+		t.Errorf("paths = %q, want %q", got, want)
+	}
+}
+
+func TestPlanIncremental(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{Files: lockTestFiles(), Incremental: true, RelativePaths: true}
+	if _, err := WriteFiles(dir, opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "LICENSE")); err != nil {
+		t.Fatal(err)
+	}
+	opts.Files[0].Content = []byte("contributing, updated\n")
+
+	ops, err := Plan(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Operation{
+		{Action: ActionOverwrite, Path: "CONTRIBUTING.md", Size: 22, Mode: "0644"},
+		{Action: ActionCreate, Path: "LICENSE", Size: 8, Mode: "0644"},
+	}
+	if !slices.Equal(ops, want) {
+		t.Errorf("Plan = %+v, want %+v", ops, want)
+	}
+
+	// The real run must do what the plan said.
+	result, err := WriteFiles(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.FilesOverwritten, []string{"CONTRIBUTING.md"}) || !slices.Equal(result.FilesCreated, []string{"LICENSE"}) {
+		t.Errorf("result = %+v, want the planned overwrite and create", result)
+	}
+
+	ops, err = Plan(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, op := range ops {
+		if op.Action != ActionSkip || op.Conflict {
+			t.Errorf("after the run, %s planned as %s (conflict %v), want a skip", op.Path, op.Action, op.Conflict)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package initcore
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Overwrite policies for File.Policy. Each decides what happens when the
+// file's destination already exists, in place of Options.Force and
+// Options.Append.
+const (
+	// PolicyFail fails with a *CollisionError, even with Options.Force.
+	PolicyFail = "fail"
+
+	// PolicySkip leaves the existing file alone and reports it as skipped.
+	PolicySkip = "skip"
+
+	// PolicyOverwrite replaces the existing file, even without
+	// Options.Force.
+	PolicyOverwrite = "overwrite"
+
+	// PolicyAppend appends to the existing file, as File.Append does.
+	PolicyAppend = "append"
+)
+
+// Policies lists the valid File.Policy values, besides "".
+var Policies = []string{PolicyFail, PolicySkip, PolicyOverwrite, PolicyAppend}
+
+// checkPolicies returns an error naming the first file whose Policy is not
+// one of Policies.
+func checkPolicies(files []File) error {
+	for _, f := range files {
+		if f.Policy != "" && !slices.Contains(Policies, f.Policy) {
+			return fmt.Errorf("%s: unknown overwrite policy %q (want one of %v)", f.DestName, f.Policy, Policies)
+		}
+	}
+	return nil
+}
+
+// overwrites reports whether an existing destination for f is replaced:
+// by f.Policy if it has one, otherwise by opts.Force.
+func (opts Options) overwrites(f File) bool {
+	switch f.Policy {
+	case "":
+		return opts.Force
+	case PolicyOverwrite:
+		return true
+	}
+	return false
+}
+
+// keeps reports whether an existing destination for f is left alone.
+func (opts Options) keeps(f File) bool {
+	return f.Policy == PolicySkip
+}
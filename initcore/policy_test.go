@@ -0,0 +1,64 @@
+package initcore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestPolicies(t *testing.T) {
+	tests := []struct {
+		policy      string
+		force       bool
+		wantErr     error
+		wantContent string
+		wantList    func(*Result) []string
+	}{
+		{policy: PolicyFail, force: true, wantErr: ErrFileExists, wantContent: "old\n"},
+		{policy: PolicySkip, force: true, wantContent: "old\n", wantList: func(r *Result) []string { return r.FilesSkipped }},
+		{policy: PolicyOverwrite, wantContent: "new\n", wantList: func(r *Result) []string { return r.FilesOverwritten }},
+		{policy: PolicyAppend, wantContent: "old\nnew\n", wantList: func(r *Result) []string { return r.FilesAppended }},
+		{policy: "", wantErr: ErrFileExists, wantContent: "old\n"},
+		{policy: "", force: true, wantContent: "new\n", wantList: func(r *Result) []string { return r.FilesOverwritten }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "NOTES.md")
+			if err := os.WriteFile(path, []byte("old\n"), FileMode); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := WriteFiles(dir, Options{
+				Files: []File{{Content: []byte("new\n"), DestName: "NOTES.md", Policy: tt.policy}},
+				Force: tt.force,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got, _ := os.ReadFile(path); string(got) != tt.wantContent {
+				t.Errorf("content = %q, want %q", got, tt.wantContent)
+			}
+			if tt.wantList != nil && !slices.Equal(tt.wantList(result), []string{path}) {
+				t.Errorf("result = %+v, want %s listed", result, path)
+			}
+		})
+	}
+}
+
+func TestUnknownPolicy(t *testing.T) {
+	dir := t.TempDir()
+	_, err := WriteFiles(dir, Options{Files: []File{
+		{Content: []byte("license\n"), DestName: "LICENSE"},
+		{Content: []byte("new\n"), DestName: "NOTES.md", Policy: "merge"},
+	}})
+	if err == nil {
+		t.Fatal("want an error for an unknown policy")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("%d files written despite the unknown policy", len(entries))
+	}
+}
@@ -0,0 +1,75 @@
+package initcore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// validSetName matches the template set names a registry may serve. It
+// keeps names usable as a URL path segment and a cache filename.
+var validSetName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// FetchTemplateSet resolves the named template set against a registry at
+// baseURL: NAME.tar.gz.sha256 is fetched first, then NAME.tar.gz, which must
+// match it. If cacheDir is non-empty, archives are kept there keyed by name
+// and checksum, so a set is only downloaded again when its checksum
+// changes. Files are limited to maxFileSize as in ReadArchive.
+func FetchTemplateSet(ctx context.Context, client *http.Client, baseURL, name, cacheDir string, maxFileSize int64) ([]File, error) {
+	if !validSetName.MatchString(name) {
+		return nil, fmt.Errorf("invalid template set name %q", name)
+	}
+	archiveURL := strings.TrimSuffix(baseURL, "/") + "/" + name + ".tar.gz"
+
+	sumData, err := download(ctx, client, archiveURL+".sha256", maxChecksumSize)
+	if err != nil {
+		return nil, err
+	}
+	// Accept sha256sum output ("HEX  NAME") as well as a bare digest.
+	fields := strings.Fields(string(sumData))
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty checksum for template set %s", name)
+	}
+	sum := strings.ToLower(fields[0])
+
+	var cachePath string
+	if cacheDir != "" {
+		cachePath = filepath.Join(cacheDir, name+"-"+sum+".tar.gz")
+		data, err := os.ReadFile(cachePath)
+		if err == nil && checkSHA256(data, sum) == nil {
+			return ReadArchive(bytes.NewReader(data), maxFileSize)
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("reading cached template set: %w", err)
+		}
+	}
+
+	data, err := download(ctx, client, archiveURL, MaxArchiveSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSHA256(data, sum); err != nil {
+		return nil, err
+	}
+	files, err := ReadArchive(bytes.NewReader(data), maxFileSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if cachePath != "" {
+		if err := os.MkdirAll(cacheDir, DirMode); err != nil {
+			return nil, fmt.Errorf("caching template set: %w", err)
+		}
+		if err := os.WriteFile(cachePath, data, FileMode); err != nil {
+			return nil, fmt.Errorf("caching template set: %w", err)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,155 @@
+package initcore
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// MaxArchiveSize is the largest template archive, in bytes, that
+// FetchArchive and FetchTemplateSet will download.
+const MaxArchiveSize = 64 << 20
+
+// maxChecksumSize is the largest checksum file FetchTemplateSet will
+// download; sha256sum output for one file is well under it.
+const maxChecksumSize = 4 << 10
+
+// FetchArchive downloads a gzip-compressed tar of templates from url and
+// returns its regular files. If wantSHA256 is non-empty, the hex-encoded
+// SHA-256 of the downloaded bytes must match it before anything is
+// extracted. maxFileSize limits each extracted file as in ReadArchive.
+func FetchArchive(ctx context.Context, client *http.Client, url, wantSHA256 string, maxFileSize int64) ([]File, error) {
+	data, err := download(ctx, client, url, MaxArchiveSize)
+	if err != nil {
+		return nil, err
+	}
+	if wantSHA256 != "" {
+		if err := checkSHA256(data, wantSHA256); err != nil {
+			return nil, err
+		}
+	}
+	return ReadArchive(bytes.NewReader(data), maxFileSize)
+}
+
+// download returns the body of a GET request for url, which must succeed
+// with 200 OK. A body longer than limit bytes is an error.
+func download(ctx context.Context, client *http.Client, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching templates: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching templates: %s: %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading templates: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("fetching templates: %s is larger than the %d-byte download limit", url, limit)
+	}
+	return data, nil
+}
+
+// checkSHA256 returns an error unless the hex SHA-256 of data is want.
+func checkSHA256(data []byte, want string) error {
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, want) {
+		return fmt.Errorf("template checksum mismatch: got %s, want %s", got, want)
+	}
+	return nil
+}
+
+// ReadArchive reads a gzip-compressed tar stream and returns one File per
+// regular file entry, named by its cleaned path within the archive.
+// Directories and other entry types are skipped. A file larger than
+// maxFileSize bytes fails the whole read before its content is loaded; zero
+// means no limit.
+func ReadArchive(r io.Reader, maxFileSize int64) ([]File, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("opening gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	var files []File
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if maxFileSize > 0 && hdr.Size > maxFileSize {
+			return nil, &FileTooLargeError{Name: hdr.Name, Size: hdr.Size, Limit: maxFileSize}
+		}
+
+		content, err := ReadLimited(tr, hdr.Name, maxFileSize)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", hdr.Name, err)
+		}
+		files = append(files, File{
+			Content:  content,
+			DestName: strings.TrimPrefix(path.Clean(hdr.Name), "./"),
+		})
+	}
+
+	if len(files) == 0 {
+		return nil, errors.New("template archive contains no files")
+	}
+	return files, nil
+}
+
+// FileTooLargeError reports a template file over the size limit.
+type FileTooLargeError struct {
+	Name  string
+	Size  int64
+	Limit int64
+}
+
+func (e *FileTooLargeError) Error() string {
+	if e.Size < 0 {
+		return fmt.Sprintf("%s exceeds the %d-byte file size limit", e.Name, e.Limit)
+	}
+	return fmt.Sprintf("%s is %d bytes, over the %d-byte file size limit", e.Name, e.Size, e.Limit)
+}
+
+// ReadLimited reads r to the end, failing with a *FileTooLargeError for
+// name as soon as more than limit bytes arrive. A limit of zero or less
+// means no limit.
+func ReadLimited(r io.Reader, name string, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(r)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, &FileTooLargeError{Name: name, Size: -1, Limit: limit}
+	}
+	return data, nil
+}
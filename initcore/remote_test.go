@@ -0,0 +1,29 @@
+package initcore
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDownloadLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 16)))
+	}))
+	defer srv.Close()
+
+	data, err := download(context.Background(), srv.Client(), srv.URL, 16)
+	if err != nil {
+		t.Fatalf("body at the limit: %v", err)
+	}
+	if len(data) != 16 {
+		t.Errorf("got %d bytes, want 16", len(data))
+	}
+
+	_, err = download(context.Background(), srv.Client(), srv.URL, 15)
+	if err == nil || !strings.Contains(err.Error(), "download limit") {
+		t.Errorf("body over the limit: err = %v, want download limit error", err)
+	}
+}
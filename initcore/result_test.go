@@ -0,0 +1,118 @@
+package initcore
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+// jsonKeys returns the sorted top-level keys of v marshaled as JSON.
+func jsonKeys(t *testing.T, v any) []string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// The JSON keys are a contract with scripts and MCP clients. Changing them
+// means bumping the schema version.
+func TestResultJSONKeys(t *testing.T) {
+	ok := true
+	full := Result{
+		SchemaVersion:    ResultSchemaVersion,
+		Directory:        "/d",
+		FilesCreated:     []string{"a"},
+		FilesOverwritten: []string{"b"},
+		FilesAppended:    []string{"c"},
+		FilesExcluded:    []string{"d"},
+		FilesRemoved:     []string{"e"},
+		FilesSkipped:     []string{"f"},
+		BytesWritten:     1,
+		DryRun:           true,
+		GitInitialized:   &ok,
+		GitError:         "g",
+		Warnings:         []string{"w"},
+	}
+	want := []string{
+		"bytes_written", "directory", "dry_run", "files_appended", "files_created",
+		"files_excluded", "files_overwritten", "files_removed", "files_skipped",
+		"git_error", "git_initialized", "schema_version", "warnings",
+	}
+	if got := jsonKeys(t, full); !slices.Equal(got, want) {
+		t.Errorf("Result keys = %q, want %q", got, want)
+	}
+
+	want = []string{"bytes_written", "directory", "files_created", "schema_version"}
+	if got := jsonKeys(t, Result{}); !slices.Equal(got, want) {
+		t.Errorf("empty Result keys = %q, want %q", got, want)
+	}
+	if ResultSchemaVersion != 1 {
+		t.Errorf("ResultSchemaVersion = %d; update this test along with the schema", ResultSchemaVersion)
+	}
+}
+
+func TestVerifyReportJSONKeys(t *testing.T) {
+	want := []string{"directory", "files", "ok", "schema_version", "warnings"}
+	if got := jsonKeys(t, VerifyReport{Warnings: []string{"w"}}); !slices.Equal(got, want) {
+		t.Errorf("VerifyReport keys = %q, want %q", got, want)
+	}
+}
+
+func TestOperationJSONKeys(t *testing.T) {
+	want := []string{"action", "conflict", "mode", "path", "size"}
+	if got := jsonKeys(t, Operation{}); !slices.Equal(got, want) {
+		t.Errorf("Operation keys = %q, want %q", got, want)
+	}
+}
+
+func TestSortFiles(t *testing.T) {
+	unsorted := func() []string { return []string{"c", "a", "b"} }
+	r := Result{
+		FilesCreated:     unsorted(),
+		FilesOverwritten: unsorted(),
+		FilesAppended:    unsorted(),
+		FilesExcluded:    unsorted(),
+		FilesRemoved:     unsorted(),
+		FilesSkipped:     unsorted(),
+	}
+	r.sortFiles()
+
+	for name, list := range map[string][]string{
+		"FilesCreated":     r.FilesCreated,
+		"FilesOverwritten": r.FilesOverwritten,
+		"FilesAppended":    r.FilesAppended,
+		"FilesExcluded":    r.FilesExcluded,
+		"FilesRemoved":     r.FilesRemoved,
+		"FilesSkipped":     r.FilesSkipped,
+	} {
+		if !slices.Equal(list, []string{"a", "b", "c"}) {
+			t.Errorf("%s = %q, want it sorted", name, list)
+		}
+	}
+}
+
+func TestWriteFilesSortsResult(t *testing.T) {
+	dir := t.TempDir()
+	result, err := WriteFiles(dir, Options{Files: []File{
+		{Content: []byte("z\n"), DestName: "z.md"},
+		{Content: []byte("a\n"), DestName: "a.md"},
+		{Content: []byte("m\n"), DestName: "docs/m.md"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.IsSorted(result.FilesCreated) {
+		t.Errorf("FilesCreated = %q, want it sorted", result.FilesCreated)
+	}
+}
@@ -0,0 +1,45 @@
+package initcore
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"syscall"
+	"time"
+)
+
+// retryBaseDelay is the wait before the first retry; it doubles each time.
+const retryBaseDelay = 10 * time.Millisecond
+
+// isTransient reports whether a write error is worth retrying.
+func isTransient(err error) bool {
+	return errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.EAGAIN)
+}
+
+// writeFile writes content to path, retrying transient failures up to
+// opts.Retries times with exponential backoff.
+func (opts Options) writeFile(path string, content []byte) error {
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		err := opts.fs().WriteFile(path, content, FileMode)
+		if err == nil {
+			opts.logger().Debug("wrote file", "path", path, "bytes", len(content), "retries", attempt)
+			return nil
+		}
+		if attempt >= opts.Retries || !isTransient(err) {
+			return err
+		}
+
+		opts.logger().Debug("transient write failure, retrying", "path", path, "attempt", attempt+1, "delay", delay, "err", err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// logger returns opts.Logger, or a logger that discards everything.
+func (opts Options) logger() *slog.Logger {
+	if opts.Logger != nil {
+		return opts.Logger
+	}
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
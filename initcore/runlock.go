@@ -0,0 +1,50 @@
+package initcore
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RunLockFileName is the file WriteFilesContext holds in the target
+// directory while it runs, so concurrent runs on the same directory fail
+// fast instead of racing. It is separate from LockFileName, which records
+// hashes between runs and must survive them.
+const RunLockFileName = ".init.pid"
+
+// StaleRunLock is how old a run lock must be before it is treated as left
+// behind by a crashed run and taken over.
+const StaleRunLock = 10 * time.Minute
+
+// ErrInProgress is returned when another run holds the directory's run
+// lock.
+var ErrInProgress = errors.New("another init is in progress")
+
+// acquireRunLock creates dir's run lock exclusively and returns a function
+// that removes it. A lock older than StaleRunLock is removed and acquired
+// again, which is reported as reclaimed.
+func acquireRunLock(dir string) (release func(), reclaimed bool, err error) {
+	path := filepath.Join(dir, RunLockFileName)
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, FileMode)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, attempt > 0, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, false, fmt.Errorf("creating %s: %w", RunLockFileName, err)
+		}
+
+		info, statErr := os.Stat(path)
+		if attempt > 0 || statErr != nil || time.Since(info.ModTime()) < StaleRunLock {
+			return nil, false, fmt.Errorf("%w in %s (remove %s if it is not)", ErrInProgress, dir, path)
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, false, fmt.Errorf("removing stale %s: %w", RunLockFileName, err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package initcore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Transform pipes the content of every file whose DestName matches Pattern
+// (see MatchGlob) through an external command.
+type Transform struct {
+	Pattern string
+
+	// Command is split on whitespace into a program and its arguments; no
+	// shell is involved. The file content is written to its stdin and its
+	// stdout becomes the new content.
+	Command string
+}
+
+// ApplyTransforms returns a copy of files with variables substituted and
+// each matching transform applied, in order. The returned content is final,
+// so callers should not substitute vars again. A command that fails or
+// exits non-zero aborts with an error naming the file and the command.
+func ApplyTransforms(ctx context.Context, files []File, vars map[string]string, transforms []Transform) ([]File, error) {
+	out := make([]File, len(files))
+	for i, f := range files {
+		f.Content = Substitute(f.Content, vars)
+		for _, t := range transforms {
+			ok, err := MatchGlob(t.Pattern, f.DestName)
+			if err != nil {
+				return nil, fmt.Errorf("transform pattern %q: %w", t.Pattern, err)
+			}
+			if !ok {
+				continue
+			}
+			f.Content, err = runTransform(ctx, t.Command, f.Content)
+			if err != nil {
+				return nil, fmt.Errorf("transforming %s with %q: %w", f.DestName, t.Command, err)
+			}
+		}
+		out[i] = f
+	}
+	return out, nil
+}
+
+// runTransform runs command with content on stdin and returns its stdout.
+func runTransform(ctx context.Context, command string, content []byte) ([]byte, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New("empty command")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
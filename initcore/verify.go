@@ -0,0 +1,124 @@
+package initcore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Verification statuses reported by Verify.
+const (
+	VerifyOK         = "ok"
+	VerifyMissing    = "missing"
+	VerifyDrifted    = "drifted"
+	VerifyUnexpected = "unexpected"
+)
+
+// VerifyEntry is the verification status of a single path.
+type VerifyEntry struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+}
+
+// VerifyReportSchemaVersion identifies the shape of VerifyReport as emitted
+// in JSON. Bump it whenever a field is removed, renamed or changes meaning.
+const VerifyReportSchemaVersion = 1
+
+// VerifyReport is the outcome of comparing a directory against a file set.
+type VerifyReport struct {
+	SchemaVersion int           `json:"schema_version"`
+	Directory     string        `json:"directory"`
+	OK            bool          `json:"ok"`
+	Files         []VerifyEntry `json:"files"`
+
+	// Warnings notes problems that do not affect OK, such as a manifest
+	// recording a different template set version.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Verify compares dir against opts.Files after variable substitution. Each
+// template file is reported as ok, missing or drifted; any other file in dir
+// is reported as unexpected. Files matching opts.Exclude or the
+// directory's ignore file are left out entirely. The report is OK only if every entry is ok,
+// except that unexpected files are tolerated when allowExtra is set.
+func Verify(dir string, opts Options, allowExtra bool) (*VerifyReport, error) {
+	if err := checkDirectory(dir); err != nil {
+		return nil, err
+	}
+
+	files, _, _, err := opts.selectFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	ignorePatterns, err := readIgnoreFile(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{SchemaVersion: VerifyReportSchemaVersion, Directory: dir, OK: true}
+	warning, err := opts.templateVersionWarning(dir)
+	if err != nil {
+		return nil, err
+	}
+	if warning != "" {
+		report.Warnings = append(report.Warnings, warning)
+	}
+	expected := make(map[string]bool, len(files))
+
+	for _, f := range files {
+		expected[f.DestName] = true
+		destPath, err := resolvePath(dir, f.DestName)
+		if err != nil {
+			return nil, err
+		}
+
+		status := VerifyOK
+		content, err := os.ReadFile(destPath)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			status = VerifyMissing
+		case err != nil:
+			return nil, fmt.Errorf("reading %s: %w", f.DestName, err)
+		case opts.appends(f) && !opts.appended(content, opts.Render(f)):
+			status = VerifyDrifted
+		case !opts.appends(f) && !bytes.Equal(content, opts.Render(f)):
+			status = VerifyDrifted
+		}
+
+		if status != VerifyOK {
+			report.OK = false
+		}
+		report.Files = append(report.Files, VerifyEntry{Path: destPath, Status: status})
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || expected[entry.Name()] {
+			continue
+		}
+		if opts.isStateFile(entry.Name()) {
+			continue
+		}
+		if excluded, _ := matchAny(opts.Exclude, entry.Name()); excluded {
+			continue
+		}
+		if ignored, _ := matchAny(ignorePatterns, entry.Name()); ignored {
+			continue
+		}
+		if !allowExtra {
+			report.OK = false
+		}
+		report.Files = append(report.Files, VerifyEntry{
+			Path:   filepath.Join(dir, entry.Name()),
+			Status: VerifyUnexpected,
+		})
+	}
+
+	return report, nil
+}
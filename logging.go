@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// mcpLogLevels maps the syslog-style levels used by MCP logging/setLevel to
+// slog levels. MCP levels above error all map to slog.LevelError.
+var mcpLogLevels = map[string]slog.Level{
+	"debug":     slog.LevelDebug,
+	"info":      slog.LevelInfo,
+	"notice":    slog.LevelInfo + 2,
+	"warning":   slog.LevelWarn,
+	"error":     slog.LevelError,
+	"critical":  slog.LevelError,
+	"alert":     slog.LevelError,
+	"emergency": slog.LevelError,
+}
+
+// mcpLogLevel returns the MCP level name for an slog level.
+func mcpLogLevel(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "error"
+	case level >= slog.LevelWarn:
+		return "warning"
+	case level > slog.LevelInfo:
+		return "notice"
+	case level >= slog.LevelInfo:
+		return "info"
+	default:
+		return "debug"
+	}
+}
+
+// notifyHandler passes records to inner and, once the client has enabled
+// logging with logging/setLevel, also sends those at or above the client's
+// level as notifications/message.
+type notifyHandler struct {
+	inner slog.Handler
+	s     *mcpServer
+	attrs []slog.Attr
+}
+
+func (h *notifyHandler) clientEnabled(level slog.Level) bool {
+	return h.s.clientLogging.Load() && level >= h.s.clientLevel.Level()
+}
+
+func (h *notifyHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level) || h.clientEnabled(level)
+}
+
+func (h *notifyHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.clientEnabled(r.Level) {
+		data := map[string]any{"message": r.Message}
+		for _, a := range h.attrs {
+			data[a.Key] = attrValue(a)
+		}
+		r.Attrs(func(a slog.Attr) bool {
+			data[a.Key] = attrValue(a)
+			return true
+		})
+		h.s.sendNotification("notifications/message", LoggingMessageParams{
+			Level:  mcpLogLevel(r.Level),
+			Logger: h.s.ServerName,
+			Data:   data,
+		})
+	}
+
+	if h.inner.Enabled(ctx, r.Level) {
+		return h.inner.Handle(ctx, r)
+	}
+	return nil
+}
+
+// attrValue returns a's value in a form that marshals usefully to JSON.
+func attrValue(a slog.Attr) any {
+	v := a.Value.Resolve().Any()
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return v
+}
+
+func (h *notifyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &notifyHandler{
+		inner: h.inner.WithAttrs(attrs),
+		s:     h.s,
+		attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...),
+	}
+}
+
+// WithGroup groups attributes for the inner handler only; notification data
+// stays flat.
+func (h *notifyHandler) WithGroup(name string) slog.Handler {
+	return &notifyHandler{inner: h.inner.WithGroup(name), s: h.s, attrs: h.attrs}
+}
+
+// sendNotification writes a JSON-RPC notification, which has no id and gets
+// no reply.
+func (s *mcpServer) sendNotification(method string, params any) {
+	data, err := json.Marshal(JSONRPCNotification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to marshal notification: %v\n", err)
+		return
+	}
+	s.writeMessage(data)
+}
+
+// handleSetLevel sets the minimum level of log messages sent to the client.
+func (s *mcpServer) handleSetLevel(req JSONRPCRequest) []byte {
+	var params SetLevelParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return errorResponse(req.ID, -32602, "Invalid params")
+	}
+	level, ok := mcpLogLevels[params.Level]
+	if !ok {
+		return errorResponse(req.ID, -32602, fmt.Sprintf("Unknown log level %q", params.Level))
+	}
+
+	s.clientLevel.Set(level)
+	s.clientLogging.Store(true)
+	return resultMessage(req.ID, struct{}{})
+}
@@ -5,12 +5,21 @@ import (
 	"context"
 	_ "embed"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net/http"
 	"os"
-	"os/signal"
+	"os/exec"
 	"path/filepath"
-	"syscall"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hegner123/init/initcore"
 )
 
 //go:embed files/FILE1
@@ -19,343 +28,824 @@ var file1Content []byte
 //go:embed files/FILE2
 var file2Content []byte
 
-// EmbeddedFile pairs embedded content with its destination filename.
-type EmbeddedFile struct {
-	Content  []byte
-	DestName string
-}
-
 // TODO: Replace these destination filenames with the actual names you want.
-var embeddedFiles = []EmbeddedFile{
+var embeddedFiles = []initcore.File{
 	{Content: file1Content, DestName: "LICENSE"},
 	{Content: file2Content, DestName: "CONTRIBUTING.md"},
 }
 
-// Exit codes for CLI mode
-const (
-	ExitSuccess = 0
-	ExitError   = 1
-)
+// Version is the release of this binary. It is the default --server-version
+// and is recorded in --write-manifest manifests.
+const Version = "1.0.0"
 
-// Result holds the outcome of an init operation.
-type Result struct {
-	Directory    string   `json:"directory"`
-	FilesCreated []string `json:"files_created"`
-}
+// TemplateVersion is the version of the embedded template set, independent
+// of Version. Bump it whenever a file in files/ or embeddedFiles changes.
+const TemplateVersion = "1.0.0"
 
-// MCP JSON-RPC types
+// DefaultProfile is the profile used when none is requested.
+const DefaultProfile = "default"
 
-type JSONRPCRequest struct {
-	JSONRPC string          `json:"jsonrpc"`
-	ID      any             `json:"id"`
-	Method  string          `json:"method"`
-	Params  json.RawMessage `json:"params,omitempty"`
+// profiles groups embedded files into named sets selectable with --profile.
+var profiles = map[string][]initcore.File{
+	DefaultProfile: embeddedFiles,
 }
 
-type JSONRPCResponse struct {
-	JSONRPC string `json:"jsonrpc"`
-	ID      any    `json:"id"`
-	Result  any    `json:"result,omitempty"`
-	Error   *Error `json:"error,omitempty"`
+// conditionalFlags returns the sorted, distinct RequiresFlag names used by
+// files in any profile. Each one becomes a --with-NAME flag and a with_NAME
+// MCP argument.
+func conditionalFlags() []string {
+	var names []string
+	for _, files := range profiles {
+		for _, f := range files {
+			if f.RequiresFlag != "" && !slices.Contains(names, f.RequiresFlag) {
+				names = append(names, f.RequiresFlag)
+			}
+		}
+	}
+	slices.Sort(names)
+	return names
 }
 
-type Error struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-}
+// Exit codes for CLI mode
+const (
+	ExitSuccess      = 0
+	ExitError        = 1
+	ExitNoDirectory  = 2
+	ExitCollision    = 3
+	ExitIOError      = 4
+	ExitVerifyFailed = 5
+)
 
-type InitializeResult struct {
-	ProtocolVersion string       `json:"protocolVersion"`
-	ServerInfo      ServerInfo   `json:"serverInfo"`
-	Capabilities    Capabilities `json:"capabilities"`
-}
+// mapFlag collects repeatable NAME=VALUE flag values.
+type mapFlag map[string]string
 
-type ServerInfo struct {
-	Name    string `json:"name"`
-	Version string `json:"version"`
+func (m mapFlag) String() string {
+	pairs := make([]string, 0, len(m))
+	for k, v := range m {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
 }
 
-type Capabilities struct {
-	Tools map[string]bool `json:"tools"`
+func (m mapFlag) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("expected NAME=VALUE, got %q", value)
+	}
+	m[k] = v
+	return nil
 }
 
-type ToolsListResult struct {
-	Tools []Tool `json:"tools"`
-}
+// listFlag collects repeatable string flag values.
+type listFlag []string
 
-type Tool struct {
-	Name        string      `json:"name"`
-	Description string      `json:"description"`
-	InputSchema InputSchema `json:"inputSchema"`
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
 }
 
-type InputSchema struct {
-	Type       string              `json:"type"`
-	Properties map[string]Property `json:"properties"`
-	Required   []string            `json:"required"`
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
 }
 
-type Property struct {
-	Type        string `json:"type"`
-	Description string `json:"description"`
-}
+// manifestFlag is the value of --write-manifest: given alone it selects
+// initcore.DefaultManifestName, and --write-manifest=NAME picks another name.
+type manifestFlag string
 
-type ToolCallParams struct {
-	Name      string         `json:"name"`
-	Arguments map[string]any `json:"arguments"`
-}
+func (m *manifestFlag) String() string { return string(*m) }
 
-type ToolCallResult struct {
-	Content []ContentItem `json:"content"`
+func (m *manifestFlag) Set(value string) error {
+	switch value {
+	case "true":
+		*m = initcore.DefaultManifestName
+	case "false":
+		*m = ""
+	default:
+		*m = manifestFlag(value)
+	}
+	return nil
 }
 
-type ContentItem struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+func (m *manifestFlag) IsBoolFlag() bool { return true }
+
+// cliOptions holds the flags that control a CLI run.
+type cliOptions struct {
+	Directories  []string
+	Profile      string
+	Prefix       string
+	FromStdin    string
+	TemplateURL  string
+	TemplateSum  string
+	TemplateSet  string
+	RegistryURL  string
+	FetchTimeout time.Duration
+	WaitForDir   time.Duration
+	Renames      map[string]string
+	DestMap      string
+	Variables    map[string]string
+	VarSubdirs   bool
+	Exclude      []string
+	Transforms   []initcore.Transform
+	Enable       []string
+	MaxTotalSize int64
+	MaxFileSize  int64
+	Retries      int
+	ModTime      string
+	Owner        string
+	ExpandEnv    bool
+	Force        bool
+	KeepNewer    bool
+	NewerThan    string
+	CleanDir     bool
+	IfEmpty      bool
+	EmptyIgnore  []string
+	IAmSure      bool
+	Incremental  bool
+	FollowLinks  bool
+	Append       bool
+	AppendMarker string
+	EnsureEOL    bool
+	DryRun       bool
+	Verify       bool
+	AllowExtra   bool
+	Clean        bool
+	Quiet        bool
+	NoColor      bool
+	OutputFormat string
+	GitInit      bool
+	GitAdd       bool
+	PrintConfig  bool
+	List         bool
+	Cat          string
+	Stream       bool
+	Stats        bool
+	Count        bool
+	AuditLog     string
+	Manifest     string
+	RelativePath bool
+
+	// ContinueOnError keeps going after a directory fails when several
+	// are given.
+	ContinueOnError bool
 }
 
 func main() {
+	var opts cliOptions
+	renames := mapFlag{}
+	variables := mapFlag{}
+	var exclude listFlag
+
 	cliMode := flag.Bool("cli", false, "Run in CLI mode (default is MCP server mode)")
-	directory := flag.String("directory", "", "Absolute path to the target directory")
+	selftest := flag.Bool("selftest", false, "Check the embedded files and a write round-trip, then exit")
+	templateVersion := flag.Bool("template-version", false, "Print the version of the embedded template set and exit")
+	var directories listFlag
+	flag.Var(&directories, "directory", "Absolute path to the target directory (repeatable)")
+	flag.DurationVar(&opts.WaitForDir, "wait-for-directory", 0, "If the directory is missing, wait up to this long for it to appear before failing (CLI mode)")
+	flag.BoolVar(&opts.ContinueOnError, "continue-on-error", false, "With several --directory values, keep going after one fails (CLI mode)")
+	var serverOpts serverOptions
+	flag.StringVar(&serverOpts.ServerName, "server-name", "init", "Server name reported in the MCP initialize response")
+	flag.StringVar(&serverOpts.ServerVersion, "server-version", Version, "Server version reported in the MCP initialize response")
+	flag.StringVar(&serverOpts.Framing, "framing", FramingNewline, "MCP message framing: newline or content-length")
+	flag.IntVar(&serverOpts.ToolsPageSize, "tools-page-size", 0, "Maximum tools per tools/list page (0 = no pagination)")
+	enableTools := flag.String("enable-tools", "", "Comma-separated MCP tools to offer, e.g. init,describe_file (default all)")
+	errorCodes := mapFlag{}
+	flag.Var(errorCodes, "error-code", "Use JSON-RPC error CODE (-32099 to -32000) for MCP tool failures of KIND, e.g. collision=-32001 (KIND=CODE, repeatable)")
+	var allowEnv listFlag
+	flag.Var(&allowEnv, "allow-env", "Let the MCP expand_env argument expand environment variable NAME (repeatable; none by default)")
+	flag.BoolVar(&serverOpts.Strict, "strict", false, "Reject MCP messages whose jsonrpc field is not \"2.0\" with an Invalid Request error")
+	flag.BoolVar(&serverOpts.ReadOnly, "readonly", false, "Hide and refuse the MCP tools that write files (init, clean)")
+	flag.DurationVar(&serverOpts.CallTimeout, "call-timeout", 0, "Abort and roll back MCP tool calls that run longer than this (0 = no timeout)")
+	flag.StringVar(&opts.Prefix, "prefix", "", "Place every file under SUBDIR of the target directory, creating it as needed")
+	flag.StringVar(&opts.Profile, "profile", DefaultProfile, "Named set of template files to write")
+	flag.StringVar(&opts.FromStdin, "from-stdin", "", "Write stdin to DESTNAME instead of the embedded files (CLI mode)")
+	flag.StringVar(&opts.TemplateURL, "template-url", "", "Fetch templates from a .tar.gz at this URL instead of the embedded files (CLI mode)")
+	flag.StringVar(&opts.TemplateSet, "template-set", "", "Fetch the named template set from the registry instead of the embedded files (CLI mode)")
+	flag.StringVar(&opts.RegistryURL, "registry-url", os.Getenv("INIT_REGISTRY_URL"), "Base URL of the --template-set registry (default $INIT_REGISTRY_URL)")
+	flag.StringVar(&opts.TemplateSum, "template-sha256", "", "Expected hex SHA-256 of the --template-url archive")
+	flag.DurationVar(&opts.FetchTimeout, "template-timeout", 30*time.Second, "Timeout for fetching --template-url")
+	flag.BoolVar(&opts.Force, "force", false, "Overwrite files that already exist")
+	flag.BoolVar(&opts.KeepNewer, "no-clobber-newer", false, "When overwriting, skip files modified after the --newer-than reference")
+	flag.StringVar(&opts.NewerThan, "newer-than", "", "Reference time for --no-clobber-newer: an RFC3339 timestamp (default: this binary's modification time)")
+	flag.BoolVar(&opts.IfEmpty, "if-empty", false, "Refuse to write unless the directory is empty, apart from --if-empty-ignore entries")
+	var emptyIgnore listFlag
+	flag.Var(&emptyIgnore, "if-empty-ignore", "Entry pattern --if-empty tolerates (repeatable; default .git)")
+	flag.BoolVar(&opts.CleanDir, "clean-dir", false, "Destructive: remove existing files matching the template set, by name or content, before writing")
+	flag.BoolVar(&opts.IAmSure, "i-am-sure", false, "Confirm --clean-dir without the interactive prompt")
+	flag.BoolVar(&opts.ExpandEnv, "expand-env", false, "Expand $VAR and ${VAR} in destination names from the environment")
+	flag.BoolVar(&opts.Incremental, "incremental", false, "Skip files unchanged since the last run recorded in .init.lock, and update it")
+	flag.BoolVar(&opts.FollowLinks, "follow-symlinks", false, "Write through destinations that are symlinks instead of refusing")
+	flag.BoolVar(&opts.Append, "append", false, "Append content to files that already exist instead of failing; skipped if already appended")
+	flag.StringVar(&opts.AppendMarker, "append-marker", "", "With --append, write this line before each appended block and use it to detect earlier appends")
+	flag.BoolVar(&opts.EnsureEOL, "ensure-trailing-newline", false, "Append a newline to file content that does not end with one")
+	flag.BoolVar(&opts.DryRun, "dry-run", false, "Print the planned operations as JSON without writing anything (CLI mode)")
+	flag.BoolVar(&opts.Verify, "verify", false, "Report whether the directory matches the template set without writing (CLI mode)")
+	flag.BoolVar(&opts.Clean, "clean", false, "Remove previously written template files whose content is unchanged (CLI mode)")
+	flag.BoolVar(&opts.AllowExtra, "allow-extra", false, "With --verify, tolerate files that are not part of the template set")
+	flag.StringVar(&opts.OutputFormat, "output-format", "", "CLI output format: text or json (default text on a terminal, json otherwise)")
+	flag.BoolVar(&opts.NoColor, "no-color", false, "Never color text output, even on a terminal (also set by the NO_COLOR environment variable)")
+	flag.BoolVar(&opts.Quiet, "quiet", false, "Suppress JSON output; rely on the exit code (CLI mode)")
+	flag.BoolVar(&opts.GitInit, "git-init", false, "Run git init in the directory after writing (CLI mode)")
+	flag.BoolVar(&opts.GitAdd, "git-add", false, "With --git-init, also stage the created files")
+	flag.BoolVar(&opts.RelativePath, "relative-paths", false, "Report file paths relative to --directory instead of absolute")
+	flag.StringVar(&opts.AuditLog, "audit-log", "", "Append a JSON line per file written or removed (time, directory, dest, action, bytes, sha256) to this file (CLI mode)")
+	var manifest manifestFlag
+	flag.Var(&manifest, "write-manifest", "After a successful run, write a JSON manifest of the result and file hashes to "+initcore.DefaultManifestName+", or to NAME with --write-manifest=NAME (CLI mode)")
+	flag.BoolVar(&opts.Stats, "stats", false, "After writing, print per-extension counts, total lines and the largest file to stderr (CLI mode)")
+	flag.BoolVar(&opts.Stream, "stream", false, "Print one JSON line per file as it is written, then the JSON summary (CLI mode)")
+	flag.StringVar(&opts.Cat, "cat", "", "Print the content of the file DESTNAME, with --var values substituted, to stdout and exit; implies --cli, no --directory needed")
+	flag.BoolVar(&opts.Count, "count", false, "Print how many files would be written, without writing; exit 1 if none (CLI mode)")
+	flag.BoolVar(&opts.List, "list", false, "Print the files in the selected profile and exit; implies --cli, no --directory needed")
+	flag.BoolVar(&opts.PrintConfig, "print-config", false, "Print the resolved configuration as JSON and exit without writing (CLI mode)")
+	flag.StringVar(&opts.ModTime, "mtime", "now", "Modification time for written files: an RFC3339 timestamp, or now")
+	flag.StringVar(&opts.Owner, "owner", "", "Change the owner of written files to UID:GID, e.g. 1000:1000 (usually needs root)")
+	flag.IntVar(&opts.Retries, "retries", 0, "Retry a file write failing with a transient error up to N times")
+	debug := flag.Bool("debug", false, "Log debug details about file operations to stderr")
+	flag.Int64Var(&opts.MaxFileSize, "max-file-size", 10<<20, "Reject any single --template-url or --from-stdin file larger than this many bytes (0 = no limit)")
+	flag.Int64Var(&opts.MaxTotalSize, "max-total-size", 0, "Abort if the combined size of all files exceeds this many bytes (0 = no limit)")
+	flag.Var(renames, "rename", "Write embedded file OLD as NEW (OLD=NEW, repeatable)")
+	flag.StringVar(&opts.DestMap, "dest-map", "", "Read OLD=NEW renames from this file, one per line or as a JSON object; --rename entries take precedence")
+	flag.Var(variables, "var", "Replace {{NAME}} in file content and destination names with VALUE (NAME=VALUE, repeatable)")
+	flag.BoolVar(&opts.VarSubdirs, "var-subdirs", false, "Allow --var values containing path separators in destination names, creating subdirectories")
+	var transforms listFlag
+	flag.Var(&transforms, "transform", "Pipe files whose destination matches PATTERN through CMD before writing (PATTERN=CMD, repeatable)")
+	flag.Var(&exclude, "exclude", "Skip files whose destination matches the glob PATTERN, e.g. *.tmp or .git/** (repeatable)")
+	with := make(map[string]*bool)
+	for _, name := range conditionalFlags() {
+		with[name] = flag.Bool("with-"+name, false, fmt.Sprintf("Also write the optional files that require %q", name))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "completion" {
+		if len(os.Args) != 3 {
+			fmt.Fprintf(os.Stderr, "Usage: init completion %s\n", strings.Join(completionShells, "|"))
+			os.Exit(ExitError)
+		}
+		if err := writeCompletion(os.Stdout, os.Args[2], flag.CommandLine); err != nil {
+			fmt.Fprintf(os.Stderr, errorLabel+"%v\n", err)
+			os.Exit(ExitError)
+		}
+		return
+	}
 
 	flag.Parse()
+	if useColor(os.Stderr, opts.NoColor) {
+		errorLabel = colorize(true, colorRed, "Error:") + " "
+	}
 
-	if *cliMode {
-		runCLI(*directory)
+	opts.Renames = renames
+	opts.Variables = variables
+	opts.Exclude = exclude
+	opts.EmptyIgnore = emptyIgnore
+	opts.Manifest = string(manifest)
+	opts.Directories = directories
+	for _, t := range transforms {
+		pattern, command, ok := strings.Cut(t, "=")
+		if !ok || pattern == "" || strings.TrimSpace(command) == "" {
+			fmt.Fprintf(os.Stderr, errorLabel+"invalid --transform %q: expected PATTERN=CMD\n", t)
+			os.Exit(ExitError)
+		}
+		opts.Transforms = append(opts.Transforms, initcore.Transform{Pattern: pattern, Command: command})
+	}
+	for _, name := range conditionalFlags() {
+		if *with[name] {
+			opts.Enable = append(opts.Enable, name)
+		}
+	}
+
+	logLevel := slog.LevelInfo
+	if *debug {
+		logLevel = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))
+
+	if *templateVersion {
+		fmt.Println(TemplateVersion)
 		return
 	}
 
-	runMCPServer()
+	if *selftest {
+		if !runSelftest(os.Stdout) {
+			os.Exit(ExitError)
+		}
+		return
+	}
+
+	if *cliMode || opts.List || opts.Cat != "" {
+		runCLI(opts, logger)
+		return
+	}
+
+	switch serverOpts.Framing {
+	case FramingNewline, FramingContentLength:
+	default:
+		fmt.Fprintf(os.Stderr, errorLabel+"unknown --framing %q (want %s or %s)\n", serverOpts.Framing, FramingNewline, FramingContentLength)
+		os.Exit(ExitError)
+	}
+
+	if len(errorCodes) > 0 {
+		codes, err := parseErrorCodes(errorCodes)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, errorLabel+"%v\n", err)
+			os.Exit(ExitError)
+		}
+		serverOpts.ErrorCodes = codes
+	}
+
+	if *enableTools != "" {
+		tools, err := parseEnabledTools(*enableTools)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, errorLabel+"%v\n", err)
+			os.Exit(ExitError)
+		}
+		serverOpts.EnabledTools = tools
+	}
+
+	serverOpts.AllowEnv = allowEnv
+	serverOpts.MaxTotalSize = opts.MaxTotalSize
+	serverOpts.Retries = opts.Retries
+	serverOpts.Logger = logger
+	if err := runMCPServer(serverOpts); err != nil {
+		os.Exit(ExitError)
+	}
 }
 
-func runCLI(directory string) {
-	if directory == "" {
-		fmt.Fprintln(os.Stderr, "Error: --directory is required in CLI mode")
+func runCLI(opts cliOptions, logger *slog.Logger) {
+	switch opts.OutputFormat {
+	case "":
+		opts.OutputFormat = FormatJSON
+		if isTerminal(os.Stdout) {
+			opts.OutputFormat = FormatText
+		}
+	case FormatText, FormatJSON:
+	default:
+		fmt.Fprintf(os.Stderr, errorLabel+"unknown --output-format %q (want %s or %s)\n", opts.OutputFormat, FormatText, FormatJSON)
 		os.Exit(ExitError)
 	}
 
-	result, err := writeFiles(directory)
+	files, err := selectProfile(opts.Profile)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, errorLabel+"%v\n", err)
 		os.Exit(ExitError)
 	}
 
-	output, err := json.Marshal(result)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error marshaling result: %v\n", err)
+	if opts.List {
+		printResult(opts, initcore.List(files), ExitSuccess)
+	}
+
+	if len(opts.Directories) == 0 && opts.Cat == "" {
+		fmt.Fprintln(os.Stderr, errorLabel+"--directory is required in CLI mode")
 		os.Exit(ExitError)
 	}
 
-	fmt.Println(string(output))
-}
+	if opts.TemplateSet != "" {
+		if opts.TemplateURL != "" {
+			fmt.Fprintln(os.Stderr, errorLabel+"--template-set and --template-url are mutually exclusive")
+			os.Exit(ExitError)
+		}
+		if opts.RegistryURL == "" {
+			fmt.Fprintln(os.Stderr, errorLabel+"--template-set needs --registry-url or INIT_REGISTRY_URL")
+			os.Exit(ExitError)
+		}
+		client := &http.Client{Timeout: opts.FetchTimeout}
+		files, err = initcore.FetchTemplateSet(context.Background(), client, opts.RegistryURL, opts.TemplateSet, templateSetCache(), opts.MaxFileSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, errorLabel+"%v\n", err)
+			os.Exit(ExitError)
+		}
+	}
+
+	if opts.TemplateURL != "" {
+		client := &http.Client{Timeout: opts.FetchTimeout}
+		files, err = initcore.FetchArchive(context.Background(), client, opts.TemplateURL, opts.TemplateSum, opts.MaxFileSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, errorLabel+"%v\n", err)
+			os.Exit(ExitError)
+		}
+	}
+
+	if opts.FromStdin != "" {
+		content, err := initcore.ReadLimited(os.Stdin, "stdin", opts.MaxFileSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, errorLabel+"reading stdin: %v\n", err)
+			os.Exit(ExitError)
+		}
+		files = []initcore.File{{Content: content, DestName: opts.FromStdin}}
+	}
+
+	renames := opts.Renames
+	if opts.DestMap != "" {
+		destMap, err := loadDestMap(opts.DestMap)
+		if err == nil {
+			// Check the map on its own first, so errors name the file.
+			_, err = initcore.ApplyRenames(files, destMap)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, errorLabel+"--dest-map %s: %v\n", opts.DestMap, err)
+			os.Exit(ExitError)
+		}
+		renames = maps.Clone(destMap)
+		maps.Copy(renames, opts.Renames)
+	}
 
-func writeFiles(directory string) (*Result, error) {
-	info, err := os.Stat(directory)
+	files, err = initcore.ApplyRenames(files, renames)
 	if err != nil {
-		return nil, fmt.Errorf("checking directory: %w", err)
+		fmt.Fprintf(os.Stderr, errorLabel+"%v\n", err)
+		os.Exit(ExitError)
 	}
-	if !info.IsDir() {
-		return nil, fmt.Errorf("not a directory: %s", directory)
+
+	var warnings []string
+	if opts.ExpandEnv {
+		var unset []string
+		files, unset = initcore.ExpandEnv(files)
+		for _, name := range unset {
+			warnings = append(warnings, fmt.Sprintf("environment variable %s is not set; expanded to empty", name))
+		}
 	}
 
-	var created []string
+	// Names are substituted here rather than left to initcore because
+	// transforms below consume the variables.
+	files, err = initcore.SubstituteNames(files, opts.Variables, opts.VarSubdirs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, errorLabel+"%v\n", err)
+		os.Exit(ExitError)
+	}
 
-	for _, ef := range embeddedFiles {
-		destPath := filepath.Join(directory, ef.DestName)
+	files = initcore.ApplyPrefix(files, opts.Prefix)
 
-		if _, err := os.Stat(destPath); err == nil {
-			return nil, fmt.Errorf("file already exists, refusing to overwrite: %s", destPath)
+	variables := opts.Variables
+	if len(opts.Transforms) > 0 && !opts.PrintConfig {
+		// Transforms see the substituted content, so it must not be
+		// substituted again. --print-config lists them without running them.
+		files, err = initcore.ApplyTransforms(context.Background(), files, variables, opts.Transforms)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, errorLabel+"%v\n", err)
+			os.Exit(ExitError)
 		}
+		variables = nil
+	}
 
-		if err := os.WriteFile(destPath, ef.Content, 0644); err != nil {
-			return nil, fmt.Errorf("writing %s: %w", ef.DestName, err)
+	if opts.Cat != "" {
+		i := slices.IndexFunc(files, func(f initcore.File) bool { return f.DestName == opts.Cat })
+		if i < 0 {
+			fmt.Fprintf(os.Stderr, errorLabel+"unknown file: %s\n", opts.Cat)
+			os.Exit(ExitError)
 		}
+		render := initcore.Options{Variables: variables, EnsureTrailingNewline: opts.EnsureEOL}
+		os.Stdout.Write(render.Render(files[i]))
+		os.Exit(ExitSuccess)
+	}
 
-		created = append(created, destPath)
+	if err := initcore.CheckTotalSize(files, opts.MaxTotalSize); err != nil {
+		fmt.Fprintf(os.Stderr, errorLabel+"%v\n", err)
+		os.Exit(ExitError)
 	}
 
-	return &Result{
-		Directory:    directory,
-		FilesCreated: created,
-	}, nil
-}
+	if opts.PrintConfig {
+		output, err := json.MarshalIndent(resolveConfig(opts, files), "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, errorLabel+"marshaling config: %v\n", err)
+			os.Exit(ExitError)
+		}
+		fmt.Println(string(output))
+		os.Exit(ExitSuccess)
+	}
 
-func runMCPServer() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	var modTime time.Time
+	if opts.ModTime != "now" {
+		modTime, err = time.Parse(time.RFC3339, opts.ModTime)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, errorLabel+"invalid --mtime %q: want an RFC3339 timestamp or now\n", opts.ModTime)
+			os.Exit(ExitError)
+		}
+	}
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	var keepNewerThan time.Time
+	if opts.KeepNewer {
+		keepNewerThan, err = newerThanReference(opts.NewerThan)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, errorLabel+"%v\n", err)
+			os.Exit(ExitError)
+		}
+	}
 
-	go func() {
-		<-sigChan
-		fmt.Fprintln(os.Stderr, "Received shutdown signal, exiting gracefully...")
-		cancel()
-	}()
+	var owner *initcore.Owner
+	if opts.Owner != "" {
+		owner, err = initcore.ParseOwner(opts.Owner)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, errorLabel+"%v\n", err)
+			os.Exit(ExitError)
+		}
+	}
 
-	scanner := bufio.NewScanner(os.Stdin)
+	// Only the embedded files have a known template set version.
+	templateVersion := TemplateVersion
+	if opts.TemplateURL != "" || opts.TemplateSet != "" || opts.FromStdin != "" {
+		templateVersion = ""
+	}
+
+	coreOpts := initcore.Options{
+		Files:    files,
+		Force:    opts.Force,
+		CleanDir: opts.CleanDir,
+
+		RequireEmpty: opts.IfEmpty,
+		EmptyIgnore:  opts.EmptyIgnore,
+		Append:       opts.Append,
+		Variables:    variables,
+		Exclude:      opts.Exclude,
+
+		VariableSubdirs: opts.VarSubdirs,
+		Enable:          opts.Enable,
+
+		EnsureTrailingNewline: opts.EnsureEOL,
+		Incremental:           opts.Incremental,
+		FollowSymlinks:        opts.FollowLinks,
+		RelativePaths:         opts.RelativePath,
+		WaitForDirectory:      opts.WaitForDir,
+		Manifest:              opts.Manifest,
+		ToolVersion:           Version,
+		TemplateVersion:       templateVersion,
+		AppendMarker:          opts.AppendMarker,
+		ModTime:               modTime,
+		KeepNewerThan:         keepNewerThan,
+		Owner:                 owner,
+		Retries:               opts.Retries,
+		Logger:                logger,
+	}
 
-	lineChan := make(chan string)
-	errChan := make(chan error, 1)
+	if opts.CleanDir && cliMode(opts) == "write" && !opts.IAmSure && !confirmCleanDir(opts.Directories) {
+		fmt.Fprintln(os.Stderr, errorLabel+"--clean-dir not confirmed; pass --i-am-sure to skip the prompt")
+		os.Exit(ExitError)
+	}
 
-	go func() {
-		for scanner.Scan() {
-			lineChan <- scanner.Text()
+	if opts.Stream && !opts.Quiet && cliMode(opts) == "write" {
+		opts.OutputFormat = FormatJSON
+		coreOpts.OnFile = func(ev initcore.FileEvent) {
+			line, _ := json.Marshal(ev)
+			fmt.Println(string(line))
 		}
-		if err := scanner.Err(); err != nil {
-			errChan <- err
+	}
+
+	// Warnings found so far belong in each Result. Modes that produce no
+	// Result get them on stderr instead.
+	if opts.Verify || opts.Count || (opts.DryRun && !opts.Clean) {
+		for _, w := range warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
 		}
-		close(lineChan)
-	}()
+		warnings = nil
+	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case err := <-errChan:
-			fmt.Fprintf(os.Stderr, "Scanner error: %v\n", err)
-			return
-		case line, ok := <-lineChan:
-			if !ok {
-				return
-			}
-			if line == "" {
-				continue
+	if opts.Count {
+		total := 0
+		for _, directory := range opts.Directories {
+			n, err := countWrites(directory, coreOpts)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, errorLabel+"%s: %v\n", directory, err)
+				os.Exit(exitCodeFor(err))
 			}
+			total += n
+		}
+		fmt.Println(total)
+		if total == 0 {
+			os.Exit(ExitError)
+		}
+		os.Exit(ExitSuccess)
+	}
 
-			var req JSONRPCRequest
-			if err := json.Unmarshal([]byte(line), &req); err != nil {
-				sendError(nil, -32700, "Parse error")
-				continue
-			}
+	// optsFor returns coreOpts for one directory, recording its writes and
+	// removals, including --clean and --clean-dir ones, in the audit log if
+	// there is one.
+	optsFor := func(directory string) initcore.Options { return coreOpts }
+	if mode := cliMode(opts); opts.AuditLog != "" && (mode == "write" || mode == "clean") {
+		audit, err := openAuditLog(opts.AuditLog)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, errorLabel+"%v\n", err)
+			os.Exit(ExitError)
+		}
+		optsFor = func(directory string) initcore.Options { return audit.observe(coreOpts, directory) }
+	}
+
+	if len(opts.Directories) == 1 {
+		result, exitCode, err := runDirectory(opts, optsFor(opts.Directories[0]), opts.Directories[0])
+		addWarnings(result, warnings)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, errorLabel+"%v\n", err)
+			os.Exit(exitCodeFor(err))
+		}
+		printResult(opts, result, exitCode)
+	}
 
-			handleRequest(req)
+	// With several directories the result is an array with one entry per
+	// directory, and the exit code is that of the first one to fail.
+	exitCode := ExitSuccess
+	results := make([]any, 0, len(opts.Directories))
+	for _, directory := range opts.Directories {
+		result, code, err := runDirectory(opts, optsFor(directory), directory)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, errorLabel+"%s: %v\n", directory, err)
+			if !opts.ContinueOnError {
+				os.Exit(exitCodeFor(err))
+			}
+			result, code = directoryError{Directory: directory, Error: err.Error()}, exitCodeFor(err)
+		}
+		addWarnings(result, warnings)
+		if exitCode == ExitSuccess {
+			exitCode = code
 		}
+		results = append(results, result)
 	}
+	printResult(opts, results, exitCode)
 }
 
-func handleRequest(req JSONRPCRequest) {
-	switch req.Method {
-	case "initialize":
-		handleInitialize(req)
-	case "tools/list":
-		handleToolsList(req)
-	case "tools/call":
-		handleToolsCall(req)
-	default:
-		sendError(req.ID, -32601, "Method not found")
+// countWrites returns how many files a write to directory would create,
+// overwrite or append to. Skipped files and conflicts are not counted.
+func countWrites(directory string, coreOpts initcore.Options) (int, error) {
+	ops, err := initcore.Plan(directory, coreOpts)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, op := range ops {
+		switch op.Action {
+		case initcore.ActionCreate, initcore.ActionOverwrite, initcore.ActionAppend:
+			if !op.Conflict {
+				n++
+			}
+		}
 	}
+	return n, nil
 }
 
-func handleInitialize(req JSONRPCRequest) {
-	result := InitializeResult{
-		ProtocolVersion: "2024-11-05",
-		ServerInfo: ServerInfo{
-			Name:    "init",
-			Version: "1.0.0",
-		},
-		Capabilities: Capabilities{
-			Tools: map[string]bool{
-				"list": true,
-				"call": true,
-			},
-		},
-	}
-	sendResponse(req.ID, result)
+// runDirectory performs the selected operation on one directory and returns
+// its result and the exit code for it.
+func runDirectory(opts cliOptions, coreOpts initcore.Options, directory string) (any, int, error) {
+	switch {
+	case opts.Verify:
+		report, err := initcore.Verify(directory, coreOpts, opts.AllowExtra)
+		if err != nil {
+			return nil, ExitError, err
+		}
+		if !report.OK {
+			return report, ExitVerifyFailed, nil
+		}
+		return report, ExitSuccess, nil
+	case opts.Clean:
+		coreOpts.DryRun = opts.DryRun
+		result, err := initcore.Clean(directory, coreOpts)
+		return result, ExitSuccess, err
+	case opts.DryRun:
+		ops, err := initcore.Plan(directory, coreOpts)
+		return ops, ExitSuccess, err
+	}
+
+	res, err := initcore.WriteFilesContext(context.Background(), directory, coreOpts)
+	if err != nil {
+		return nil, ExitError, err
+	}
+	if opts.GitInit {
+		var stage []string
+		if opts.GitAdd {
+			stage = slices.Concat(res.FilesCreated, res.FilesOverwritten, res.FilesAppended)
+			if !opts.RelativePath {
+				for i, p := range stage {
+					if rel, err := filepath.Rel(directory, p); err == nil {
+						stage[i] = rel
+					}
+				}
+			}
+		}
+		ok := true
+		if gitErr := gitInit(directory, stage); gitErr != nil {
+			ok = false
+			res.GitError = gitErr.Error()
+		}
+		res.GitInitialized = &ok
+	}
+	if opts.Stats {
+		collectStats(directory, coreOpts, slices.Concat(res.FilesCreated, res.FilesOverwritten, res.FilesAppended)).write(os.Stderr)
+	}
+	return res, ExitSuccess, nil
 }
 
-func handleToolsList(req JSONRPCRequest) {
-	result := ToolsListResult{
-		Tools: []Tool{
-			{
-				Name:        "init",
-				Description: "Write embedded template files to a target directory. Refuses to overwrite existing files.",
-				InputSchema: InputSchema{
-					Type: "object",
-					Properties: map[string]Property{
-						"directory": {
-							Type:        "string",
-							Description: "Absolute path to the directory where files will be created",
-						},
-					},
-					Required: []string{"directory"},
-				},
-			},
-		},
-	}
-	sendResponse(req.ID, result)
+// templateSetCache returns the directory where downloaded template sets are
+// cached, or "" if the user has no cache directory.
+func templateSetCache() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "init", "template-sets")
 }
 
-func handleToolsCall(req JSONRPCRequest) {
-	var params ToolCallParams
-	if err := json.Unmarshal(req.Params, &params); err != nil {
-		sendError(req.ID, -32602, "Invalid params")
-		return
+// newerThanReference returns the --newer-than time: the parsed timestamp,
+// or the modification time of the running binary when ref is empty.
+func newerThanReference(ref string) (time.Time, error) {
+	if ref != "" {
+		t, err := time.Parse(time.RFC3339, ref)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid --newer-than %q: want an RFC3339 timestamp", ref)
+		}
+		return t, nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("finding the binary for --no-clobber-newer: %w", err)
+	}
+	info, err := os.Stat(exe)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("finding the binary for --no-clobber-newer: %w", err)
 	}
+	return info.ModTime(), nil
+}
 
-	if params.Name != "init" {
-		sendError(req.ID, -32602, "Unknown tool")
-		return
+// confirmCleanDir asks on the terminal whether --clean-dir may remove files
+// from directories. Without a terminal on stdin there is no one to ask, so
+// it returns false.
+func confirmCleanDir(directories []string) bool {
+	if !isTerminal(os.Stdin) {
+		return false
 	}
+	fmt.Fprintf(os.Stderr, "--clean-dir will delete existing files matching the template set in %s. Continue? [y/N] ", strings.Join(directories, ", "))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
 
-	directory, ok := params.Arguments["directory"].(string)
-	if !ok || directory == "" {
-		sendError(req.ID, -32602, "Missing or invalid 'directory' parameter")
-		return
+// printResult writes result to stdout in the selected output format and
+// exits with exitCode.
+func printResult(opts cliOptions, result any, exitCode int) {
+	if opts.Quiet {
+		os.Exit(exitCode)
 	}
 
-	result, err := writeFiles(directory)
-	if err != nil {
-		sendError(req.ID, -32603, fmt.Sprintf("Init failed: %v", err))
-		return
+	if opts.OutputFormat == FormatText {
+		for _, w := range resultWarnings(result) {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		}
+		fmt.Println(formatText(result, useColor(os.Stdout, opts.NoColor), opts.RelativePath))
+		os.Exit(exitCode)
 	}
 
-	jsonResult, err := json.Marshal(result)
+	output, err := json.Marshal(result)
 	if err != nil {
-		sendError(req.ID, -32603, "Failed to marshal result")
-		return
+		fmt.Fprintf(os.Stderr, errorLabel+"marshaling result: %v\n", err)
+		os.Exit(ExitError)
 	}
 
-	response := ToolCallResult{
-		Content: []ContentItem{
-			{
-				Type: "text",
-				Text: string(jsonResult),
-			},
-		},
-	}
+	fmt.Println(string(output))
+	os.Exit(exitCode)
+}
 
-	sendResponse(req.ID, response)
+// exitCodeFor maps an error from the initcore package to a CLI exit code so
+// scripts can tell a missing directory from a collision or an I/O failure.
+func exitCodeFor(err error) int {
+	var pathErr *fs.PathError
+	switch {
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, initcore.ErrNotDirectory):
+		return ExitNoDirectory
+	case errors.Is(err, initcore.ErrFileExists), errors.Is(err, initcore.ErrNotEmpty):
+		return ExitCollision
+	case errors.Is(err, initcore.ErrUnsafePath), errors.Is(err, initcore.ErrSymlink):
+		return ExitError
+	case errors.Is(err, fs.ErrPermission), errors.As(err, &pathErr):
+		return ExitIOError
+	default:
+		return ExitError
+	}
 }
 
-func sendResponse(id any, result any) {
-	resp := JSONRPCResponse{
-		JSONRPC: "2.0",
-		ID:      id,
-		Result:  result,
+// selectProfile returns the files in the named profile, decompressed. An
+// empty name selects DefaultProfile.
+func selectProfile(name string) ([]initcore.File, error) {
+	if name == "" {
+		name = DefaultProfile
 	}
-	data, err := json.Marshal(resp)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to marshal response: %v\n", err)
-		return
+	files, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q (valid profiles: %s)", name, strings.Join(slices.Sorted(maps.Keys(profiles)), ", "))
 	}
-	fmt.Println(string(data))
+	return initcore.Decompress(files)
 }
 
-func sendError(id any, code int, message string) {
-	resp := JSONRPCResponse{
-		JSONRPC: "2.0",
-		ID:      id,
-		Error: &Error{
-			Code:    code,
-			Message: message,
-		},
-	}
-	data, err := json.Marshal(resp)
+// gitInit runs git init in directory and stages paths, if any, which are
+// relative to directory. A missing git binary is reported as an error like any
+// other failure; callers treat it as non-fatal.
+func gitInit(directory string, paths []string) error {
+	gitPath, err := exec.LookPath("git")
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to marshal error response: %v\n", err)
-		return
+		return fmt.Errorf("git not found on PATH: %w", err)
 	}
-	fmt.Println(string(data))
-}
 
+	commands := [][]string{{"init", "--quiet"}}
+	if len(paths) > 0 {
+		commands = append(commands, append([]string{"add", "--"}, paths...))
+	}
+
+	for _, args := range commands {
+		cmd := exec.Command(gitPath, args...)
+		cmd.Dir = directory
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/hegner123/init/initcore"
+)
+
+func TestGitAddRelativeDirectory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found on PATH")
+	}
+
+	files := []initcore.File{
+		{Content: []byte("contributing\n"), DestName: "CONTRIBUTING.md"},
+		{Content: []byte("license\n"), DestName: "LICENSE"},
+	}
+	for _, relative := range []bool{false, true} {
+		name := "absolute paths"
+		if relative {
+			name = "relative paths"
+		}
+		t.Run(name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			if err := os.Mkdir("d", 0o755); err != nil {
+				t.Fatal(err)
+			}
+			opts := cliOptions{GitInit: true, GitAdd: true, RelativePath: relative}
+			coreOpts := initcore.Options{Files: files, RelativePaths: relative}
+
+			result, _, err := runDirectory(opts, coreOpts, "d")
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := result.(*initcore.Result)
+			if res.GitInitialized == nil || !*res.GitInitialized {
+				t.Fatalf("git not initialized: %s", res.GitError)
+			}
+
+			out, err := exec.Command("git", "-C", "d", "diff", "--cached", "--name-only").Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Fields(string(out)); strings.Join(got, ",") != "CONTRIBUTING.md,LICENSE" {
+				t.Errorf("staged files = %v, want [CONTRIBUTING.md LICENSE]", got)
+			}
+		})
+	}
+}
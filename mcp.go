@@ -0,0 +1,1479 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/hegner123/init/initcore"
+)
+
+// MCP JSON-RPC types
+
+type JSONRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      any             `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+
+	hasID     bool
+	invalidID bool
+}
+
+// UnmarshalJSON keeps the id exactly as sent so it can be echoed back
+// verbatim, and records whether it was present at all (a notification) and
+// whether it has a type JSON-RPC allows: string, number or null.
+func (r *JSONRPCRequest) UnmarshalJSON(data []byte) error {
+	type request JSONRPCRequest
+	var raw struct {
+		request
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*r = JSONRPCRequest(raw.request)
+	r.hasID = raw.ID != nil
+	if !r.hasID {
+		return nil
+	}
+
+	switch c := raw.ID[0]; {
+	case c == '"', c == 'n', c == '-', c >= '0' && c <= '9':
+		r.ID = raw.ID
+	default:
+		r.invalidID = true
+	}
+	return nil
+}
+
+// IsNotification reports whether the request carried no id. The server
+// must never reply to a notification.
+func (r JSONRPCRequest) IsNotification() bool {
+	return !r.hasID
+}
+
+type JSONRPCResponse struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      any    `json:"id"`
+	Result  any    `json:"result,omitempty"`
+	Error   *Error `json:"error,omitempty"`
+}
+
+// JSONRPCNotification is a message from the server that expects no reply.
+type JSONRPCNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// ErrorData is the structured data attached to tool call failures so
+// clients can offer targeted remediation.
+type ErrorData struct {
+	Kind      string   `json:"kind"`
+	Path      string   `json:"path,omitempty"`
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// errorKinds lists every ErrorData.Kind classifyInitError reports.
+var errorKinds = []string{"collision", "no_directory", "not_empty", "symlink", "in_progress", "permission", "io"}
+
+// parseErrorCodes validates --error-code KIND=CODE values: each KIND must
+// be one of errorKinds and each CODE must lie in the JSON-RPC
+// implementation-defined server error range, -32099 to -32000.
+func parseErrorCodes(values map[string]string) (map[string]int, error) {
+	codes := make(map[string]int, len(values))
+	for kind, value := range values {
+		if !slices.Contains(errorKinds, kind) {
+			return nil, fmt.Errorf("unknown error kind %q in --error-code (known kinds: %s)", kind, strings.Join(errorKinds, ", "))
+		}
+		code, err := strconv.Atoi(value)
+		if err != nil || code < -32099 || code > -32000 {
+			return nil, fmt.Errorf("invalid --error-code %s=%s: want a code from -32099 to -32000", kind, value)
+		}
+		codes[kind] = code
+	}
+	return codes, nil
+}
+
+// InitializeParams is what the client declares about itself in initialize.
+type InitializeParams struct {
+	ProtocolVersion string             `json:"protocolVersion"`
+	Capabilities    ClientCapabilities `json:"capabilities"`
+	ClientInfo      ServerInfo         `json:"clientInfo"`
+}
+
+// ClientCapabilities lists the optional features a client supports. A nil
+// field means the capability was not declared.
+type ClientCapabilities struct {
+	Roots        *RootsCapability `json:"roots,omitempty"`
+	Sampling     *struct{}        `json:"sampling,omitempty"`
+	Experimental map[string]any   `json:"experimental,omitempty"`
+}
+
+type RootsCapability struct {
+	ListChanged bool `json:"listChanged,omitempty"`
+}
+
+type InitializeResult struct {
+	ProtocolVersion string       `json:"protocolVersion"`
+	ServerInfo      ServerInfo   `json:"serverInfo"`
+	Capabilities    Capabilities `json:"capabilities"`
+}
+
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type Capabilities struct {
+	Tools   map[string]bool `json:"tools"`
+	Logging *struct{}       `json:"logging,omitempty"`
+}
+
+type SetLevelParams struct {
+	Level string `json:"level"`
+}
+
+type LoggingMessageParams struct {
+	Level  string `json:"level"`
+	Logger string `json:"logger,omitempty"`
+	Data   any    `json:"data"`
+}
+
+type ToolsListParams struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type ToolsListResult struct {
+	Tools      []Tool `json:"tools"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type Tool struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	InputSchema InputSchema `json:"inputSchema"`
+}
+
+// InputSchema is the JSON Schema (draft-07 subset) describing a tool's
+// arguments.
+type InputSchema struct {
+	Type       string              `json:"type"`
+	Properties map[string]Property `json:"properties"`
+	Required   []string            `json:"required"`
+
+	// AnyOf lists alternative sets of required arguments, at least one of
+	// which must be satisfied.
+	AnyOf []Requirement `json:"anyOf,omitempty"`
+}
+
+// Requirement is an anyOf branch of an InputSchema.
+type Requirement struct {
+	Required []string `json:"required"`
+}
+
+// Property describes one argument. Items applies to arrays and
+// AdditionalProperties to objects used as string maps.
+type Property struct {
+	Type                 string    `json:"type"`
+	Description          string    `json:"description,omitempty"`
+	Items                *Property `json:"items,omitempty"`
+	MinItems             int       `json:"minItems,omitempty"`
+	AdditionalProperties *Property `json:"additionalProperties,omitempty"`
+}
+
+type ToolCallParams struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments"`
+}
+
+type ToolCallResult struct {
+	Content []ContentItem `json:"content"`
+}
+
+// ContentItem is one entry in a tool result. Type "text" uses Text; type
+// "resource" carries a file's content in Resource.
+type ContentItem struct {
+	Type     string            `json:"type"`
+	Text     string            `json:"text,omitempty"`
+	Resource *ResourceContents `json:"resource,omitempty"`
+}
+
+// ResourceContents is an embedded resource. Text content uses Text; binary
+// content is base64-encoded in Blob.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// Message framing modes for the MCP stdio transport.
+const (
+	FramingNewline       = "newline"
+	FramingContentLength = "content-length"
+)
+
+// maxMessageSize is the largest message, in bytes, the server reads. A
+// larger one is a read error rather than an allocation the client controls.
+const maxMessageSize = 16 << 20
+
+// serverOptions holds the flags that configure the MCP server.
+type serverOptions struct {
+	// ServerName and ServerVersion are reported as serverInfo.
+	ServerName    string
+	ServerVersion string
+
+	Framing       string
+	MaxTotalSize  int64
+	ToolsPageSize int
+
+	// CallTimeout bounds each tools/call request. Zero means no timeout.
+	CallTimeout time.Duration
+
+	// ReadOnly hides the tools that write to the filesystem and rejects
+	// calls to them.
+	ReadOnly bool
+
+	// ErrorCodes maps an ErrorData.Kind to the JSON-RPC code used when a
+	// tool call fails with that kind of error. Kinds not listed, and
+	// errors without a kind, use -32603.
+	ErrorCodes map[string]int
+
+	// AllowEnv lists the environment variables the expand_env argument may
+	// expand. A destination name referring to any other variable is
+	// refused, so clients cannot read arbitrary server environment
+	// variables back through the names of the files written.
+	AllowEnv []string
+
+	// Strict rejects messages whose jsonrpc member is not "2.0" with
+	// -32600 Invalid Request, instead of accepting them.
+	Strict bool
+
+	// EnabledTools, if non-nil, limits the tools offered and accepted to
+	// those named. ReadOnly still applies on top.
+	EnabledTools []string
+
+	// Retries and Logger are passed through to every initcore call.
+	Retries int
+	Logger  *slog.Logger
+}
+
+// mcpServer holds the transport state shared by the request handlers.
+type mcpServer struct {
+	serverOptions
+	out io.Writer
+	mu  sync.Mutex
+
+	// ctx is cancelled when the server shuts down. Tool calls run under it.
+	ctx context.Context
+
+	// clientLevel is the minimum level sent as notifications/message, once
+	// the client has set one with logging/setLevel.
+	clientLevel   slog.LevelVar
+	clientLogging atomic.Bool
+
+	// client is what the client sent in initialize, and initialized is set
+	// once it confirms with notifications/initialized.
+	client      InitializeParams
+	initialized bool
+
+	usage usageStats
+}
+
+// usageStats counts tool calls since the server started, for the stats
+// tool.
+type usageStats struct {
+	mu           sync.Mutex
+	started      time.Time
+	calls        map[string]int
+	filesCreated int
+}
+
+// ServerStats is the result of the stats tool.
+type ServerStats struct {
+	ToolCalls     map[string]int `json:"tool_calls"`
+	FilesCreated  int            `json:"files_created"`
+	UptimeSeconds int64          `json:"uptime_seconds"`
+
+	// TemplateVersion is the version of the embedded template set.
+	TemplateVersion string `json:"template_version"`
+}
+
+// runMCPServer serves requests on stdin until the client closes it, a
+// SIGINT or SIGTERM arrives, or reading fails. Only a read failure is
+// returned as an error.
+func runMCPServer(opts serverOptions) error {
+	s := &mcpServer{
+		serverOptions: opts,
+		out:           os.Stdout,
+		usage:         usageStats{started: time.Now(), calls: make(map[string]int)},
+	}
+	var inner slog.Handler = slog.NewTextHandler(io.Discard, nil)
+	if opts.Logger != nil {
+		inner = opts.Logger.Handler()
+	}
+	s.Logger = slog.New(&notifyHandler{inner: inner, s: s})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.ctx = ctx
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		sig := <-sigChan
+		fmt.Fprintf(os.Stderr, "Received %v, shutting down\n", sig)
+		cancel()
+	}()
+
+	reader := &lineReader{Reader: bufio.NewReader(os.Stdin)}
+
+	msgChan := make(chan []byte)
+	errChan := make(chan error, 1)
+
+	go func() {
+		defer close(msgChan)
+		for {
+			msg, err := s.readMessage(reader)
+			if err != nil {
+				if err != io.EOF {
+					errChan <- err
+				}
+				return
+			}
+			msgChan <- msg
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errChan:
+			fmt.Fprintf(os.Stderr, "Read error, shutting down: %v\n", err)
+			return err
+		case msg, ok := <-msgChan:
+			if !ok {
+				// The reader sends any error before closing msgChan.
+				select {
+				case err := <-errChan:
+					fmt.Fprintf(os.Stderr, "Read error, shutting down: %v\n", err)
+					return err
+				default:
+				}
+				fmt.Fprintln(os.Stderr, "Client closed stdin, shutting down")
+				return nil
+			}
+			if response, ok := s.parseAndDispatch(msg); ok {
+				s.writeMessage(response)
+			}
+		}
+	}
+}
+
+// parseAndDispatch handles one raw message from the client, which may be
+// arbitrary bytes, and returns the encoded response to send back. It writes
+// nothing itself. A message that parses is dispatched to handleRequest.
+// Input that is not JSON gets -32700 Parse error, and JSON that is not a
+// request object gets -32600 Invalid Request. ok is false when there is no
+// response: for blank input and for notifications.
+func (s *mcpServer) parseAndDispatch(line []byte) (response []byte, ok bool) {
+	trimmed := bytes.TrimSpace(line)
+	if len(trimmed) == 0 {
+		return nil, false
+	}
+
+	if !json.Valid(trimmed) {
+		return errorResponse(nil, -32700, "Parse error"), true
+	}
+	var req JSONRPCRequest
+	if trimmed[0] != '{' || json.Unmarshal(trimmed, &req) != nil {
+		return errorResponse(nil, -32600, "Invalid Request: not a JSON-RPC request object"), true
+	}
+
+	response = s.handleRequest(req)
+	return response, response != nil
+}
+
+// readMessage reads one JSON-RPC message using the configured framing.
+// It returns io.EOF once the input is exhausted between messages.
+func (s *mcpServer) readMessage(r *lineReader) ([]byte, error) {
+	if s.Framing == FramingContentLength {
+		return readContentLengthMessage(r.Reader)
+	}
+	return readLineMessage(r)
+}
+
+// lineReader is the server's input. It remembers a line read while
+// collecting one newline-delimited message that turned out to start the
+// next.
+type lineReader struct {
+	*bufio.Reader
+	next []byte
+}
+
+// readLine returns the saved line if there is one, and otherwise reads the
+// next line, newline included. A line longer than limit is an error.
+func (r *lineReader) readLine(limit int) ([]byte, error) {
+	if line := r.next; line != nil {
+		r.next = nil
+		return line, nil
+	}
+
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > limit {
+			return nil, fmt.Errorf("message exceeds the %d-byte message limit", maxMessageSize)
+		}
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+// readLineMessage reads a newline-delimited message. A message that is
+// pretty-printed across several lines is accumulated until it forms a
+// complete JSON value, for as long as each line could continue it. A line
+// that cannot is kept for the next call, and what came before it is
+// returned as it is, so the caller reports a parse error for the truncated
+// message and still handles the one that follows. A message longer than
+// maxMessageSize is a read error.
+func readLineMessage(r *lineReader) ([]byte, error) {
+	// The decoder pulls one line at a time and scans each as it arrives,
+	// so a syntax error always lies in the last line it was given.
+	src := &lineSource{r: r}
+	var v json.RawMessage
+	err := json.NewDecoder(src).Decode(&v)
+
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &syntaxErr) && src.lines > 1:
+		r.next = src.last
+		src.msg = src.msg[:len(src.msg)-len(src.last)]
+	case errors.Is(err, io.EOF) && len(bytes.TrimSpace(src.msg)) == 0:
+		return nil, io.EOF
+	case src.err != nil && src.err != io.EOF:
+		return nil, src.err
+	}
+	return bytes.TrimRight(src.msg, "\r\n"), nil
+}
+
+// lineSource feeds a lineReader to a json.Decoder one line at a time,
+// keeping everything it has handed over.
+type lineSource struct {
+	r     *lineReader
+	msg   []byte
+	last  []byte
+	lines int
+	err   error
+	buf   []byte
+}
+
+func (s *lineSource) Read(p []byte) (int, error) {
+	if len(s.buf) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		line, err := s.r.readLine(maxMessageSize - len(s.msg))
+		s.err = err
+		if len(line) == 0 {
+			if err == nil {
+				err = io.EOF
+			}
+			return 0, err
+		}
+		s.msg = append(s.msg, line...)
+		s.last = line
+		s.lines++
+		s.buf = line
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// readContentLengthMessage reads an LSP-style message: a block of
+// "Name: value" headers terminated by a blank line, followed by exactly
+// Content-Length bytes of body.
+func readContentLengthMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	sawHeader := false
+
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF && (sawHeader || line != "") {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if !sawHeader {
+				continue
+			}
+			break
+		}
+		sawHeader = true
+
+		name, value, found := strings.Cut(line, ":")
+		if !found {
+			return nil, fmt.Errorf("malformed header: %q", line)
+		}
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid Content-Length: %q", value)
+			}
+			if n > maxMessageSize {
+				return nil, fmt.Errorf("Content-Length %d exceeds the %d-byte message limit", n, maxMessageSize)
+			}
+			length = n
+		}
+	}
+
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("reading message body: %w", err)
+	}
+	return body, nil
+}
+
+// writeMessage writes one JSON-RPC message using the configured framing.
+func (s *mcpServer) writeMessage(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Framing == FramingContentLength {
+		fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+		return
+	}
+	fmt.Fprintf(s.out, "%s\n", data)
+}
+
+// handleRequest returns the encoded response to req, or nil if it gets
+// none.
+func (s *mcpServer) handleRequest(req JSONRPCRequest) (response []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "Panic handling %q: %v\n%s", req.Method, r, debug.Stack())
+			response = nil
+			if !req.IsNotification() {
+				response = errorResponse(req.ID, -32603, "Internal error")
+			}
+		}
+	}()
+
+	if req.invalidID {
+		return errorResponse(nil, -32600, "Invalid Request: id must be a string, number, or null")
+	}
+
+	if s.Strict && req.JSONRPC != "2.0" {
+		if req.IsNotification() {
+			fmt.Fprintf(os.Stderr, "Ignoring notification %q: jsonrpc is %q, want \"2.0\"\n", req.Method, req.JSONRPC)
+			return nil
+		}
+		return errorResponse(req.ID, -32600, `Invalid Request: jsonrpc must be "2.0"`)
+	}
+
+	if req.IsNotification() {
+		s.handleNotification(req)
+		return nil
+	}
+
+	switch req.Method {
+	case "initialize":
+		return s.handleInitialize(req)
+	case "tools/list":
+		return s.handleToolsList(req)
+	case "logging/setLevel":
+		return s.handleSetLevel(req)
+	case "tools/call":
+		return s.callTool(req)
+	default:
+		return errorResponse(req.ID, -32601, "Method not found")
+	}
+}
+
+// callTool answers a tools/call request. With CallTimeout set, the call
+// runs in its own goroutine so that one stuck in a slow write still gets a
+// timeout error on time; it sees its context cancelled, and rolls back,
+// after that error has been returned. When the server is shutting down
+// instead, callTool waits for the call to finish rolling back.
+func (s *mcpServer) callTool(req JSONRPCRequest) []byte {
+	if s.CallTimeout <= 0 {
+		return s.handleToolsCall(s.ctx, req)
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.CallTimeout)
+	defer cancel()
+
+	done := make(chan []byte, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				fmt.Fprintf(os.Stderr, "Panic handling %q: %v\n%s", req.Method, r, debug.Stack())
+				done <- errorResponse(req.ID, -32603, "Internal error")
+			}
+		}()
+		done <- s.handleToolsCall(ctx, req)
+	}()
+
+	select {
+	case response := <-done:
+		return response
+	case <-ctx.Done():
+	}
+	select {
+	case response := <-done:
+		return response
+	default:
+	}
+	if s.ctx.Err() != nil {
+		return <-done
+	}
+	return errorResponse(req.ID, -32603, fmt.Sprintf("Tool call timed out after %s; partial writes are being rolled back", s.CallTimeout))
+}
+
+// handleNotification processes a request without an id. No response is
+// ever written, even for unknown methods.
+func (s *mcpServer) handleNotification(req JSONRPCRequest) {
+	switch req.Method {
+	case "notifications/initialized":
+		s.initialized = true
+		s.Logger.Debug("client initialized", "client", s.client.ClientInfo.Name, "version", s.client.ClientInfo.Version)
+	case "notifications/cancelled", "notifications/roots/list_changed":
+		// Calls run to completion and roots are not used, so there is
+		// nothing to do.
+	default:
+		fmt.Fprintf(os.Stderr, "Ignoring notification %q\n", req.Method)
+	}
+}
+
+func (s *mcpServer) handleInitialize(req JSONRPCRequest) []byte {
+	var params InitializeParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return errorResponse(req.ID, -32602, "Invalid params")
+		}
+	}
+	s.client = params
+
+	result := InitializeResult{
+		ProtocolVersion: "2024-11-05",
+		ServerInfo: ServerInfo{
+			Name:    s.ServerName,
+			Version: s.ServerVersion,
+		},
+		Capabilities: Capabilities{
+			Tools: map[string]bool{
+				"list": true,
+				"call": len(s.tools()) > 0,
+			},
+			Logging: &struct{}{},
+		},
+	}
+	return resultMessage(req.ID, result)
+}
+
+// writeTools are the tools that modify the filesystem, withheld by
+// serverOptions.ReadOnly.
+var writeTools = []string{"init", "clean"}
+
+// tools returns the tools this server offers: every tool that
+// checkToolEnabled accepts.
+func (s *mcpServer) tools() []Tool {
+	return slices.DeleteFunc(toolDefinitions(), func(t Tool) bool { return s.checkToolEnabled(t.Name) != nil })
+}
+
+// checkToolEnabled returns an error explaining why name may not be called,
+// or nil if it may. Unknown names are left for the caller to reject.
+func (s *mcpServer) checkToolEnabled(name string) error {
+	if s.ReadOnly && slices.Contains(writeTools, name) {
+		return fmt.Errorf("Tool %q is disabled: the server is read-only", name)
+	}
+	if s.EnabledTools != nil && !slices.Contains(s.EnabledTools, name) && toolExists(name) {
+		return fmt.Errorf("Tool %q is disabled on this server (enabled: %s)", name, strings.Join(s.EnabledTools, ", "))
+	}
+	return nil
+}
+
+// toolExists reports whether name is one of toolDefinitions.
+func toolExists(name string) bool {
+	return slices.ContainsFunc(toolDefinitions(), func(t Tool) bool { return t.Name == name })
+}
+
+// parseEnabledTools splits a comma-separated --enable-tools value and
+// checks every name is a known tool.
+func parseEnabledTools(value string) ([]string, error) {
+	names := []string{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !toolExists(name) {
+			var known []string
+			for _, t := range toolDefinitions() {
+				known = append(known, t.Name)
+			}
+			return nil, fmt.Errorf("unknown tool %q in --enable-tools (known tools: %s)", name, strings.Join(known, ", "))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// toolDefinitions returns every tool the server exposes, in listing order.
+func toolDefinitions() []Tool {
+	tools := []Tool{
+		{
+			Name:        "init",
+			Description: "Write embedded template files to a target directory. Refuses to overwrite existing files unless force is true.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"directory": {
+						Type:        "string",
+						Description: "Absolute path to the directory where files will be created (required unless 'directories' is given)",
+					},
+					"directories": {
+						Type:        "array",
+						Description: "Absolute paths of several directories to initialize instead of 'directory'; the result is an array with one entry per directory",
+						Items:       &Property{Type: "string"},
+						MinItems:    1,
+					},
+					"continue_on_error": {
+						Type:        "boolean",
+						Description: "With 'directories', keep going after a directory fails and report its error in the result array",
+					},
+					"relative_paths": {
+						Type:        "boolean",
+						Description: "Report file paths relative to the directory instead of absolute",
+					},
+					"profile": {
+						Type:        "string",
+						Description: "Named set of template files to write (default: \"default\")",
+					},
+					"rename": {
+						Type:                 "object",
+						Description:          "Map of embedded destination names to replacement names, e.g. {\"LICENSE\": \"LICENSE.txt\"}",
+						AdditionalProperties: &Property{Type: "string"},
+					},
+					"variables": {
+						Type:                 "object",
+						Description:          "Values for {{NAME}} placeholders in file content and destination names, e.g. {\"PROJECT\": \"demo\"}",
+						AdditionalProperties: &Property{Type: "string"},
+					},
+					"force": {
+						Type:        "boolean",
+						Description: "Overwrite files that already exist",
+					},
+					"if_empty": {
+						Type:        "boolean",
+						Description: "Refuse to write unless the directory is empty (a .git entry is allowed)",
+					},
+					"expand_env": {
+						Type:        "boolean",
+						Description: "Expand $VAR and ${VAR} in destination names from the server's environment; only variables the server allows with --allow-env",
+					},
+					"preview": {
+						Type:        "boolean",
+						Description: "Write nothing; return the planned operations and a short diff for each file that already exists",
+					},
+				},
+				Required: []string{},
+				AnyOf: []Requirement{
+					{Required: []string{"directory"}},
+					{Required: []string{"directories"}},
+				},
+			},
+		},
+		{
+			Name:        "clean",
+			Description: "Remove previously initialized template files from a directory. Files whose content no longer matches the template are left in place and reported as skipped.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"directory": {
+						Type:        "string",
+						Description: "Absolute path to the directory to clean",
+					},
+					"profile": {
+						Type:        "string",
+						Description: "Named set of template files to remove (default: \"default\")",
+					},
+					"rename": {
+						Type:                 "object",
+						Description:          "Map of embedded destination names to the names they were written as",
+						AdditionalProperties: &Property{Type: "string"},
+					},
+					"expand_env": {
+						Type:        "boolean",
+						Description: "Expand $VAR and ${VAR} in destination names, as when the files were written; only variables the server allows with --allow-env",
+					},
+					"variables": {
+						Type:                 "object",
+						Description:          "Placeholder values the files were written with, used to resolve names and compare content",
+						AdditionalProperties: &Property{Type: "string"},
+					},
+					"relative_paths": {
+						Type:        "boolean",
+						Description: "Report file paths relative to the directory instead of absolute",
+					},
+				},
+				Required: []string{"directory"},
+			},
+		},
+		{
+			Name:        "stats",
+			Description: "Report how many times each tool has been called, how many files init has created, the server uptime, and the template set version.",
+			InputSchema: InputSchema{
+				Type:       "object",
+				Properties: map[string]Property{},
+				Required:   []string{},
+			},
+		},
+		{
+			Name:        "describe_file",
+			Description: "Describe a single embedded template file (destination, mode, size, checksum) without writing it.",
+			InputSchema: InputSchema{
+				Type: "object",
+				Properties: map[string]Property{
+					"name": {
+						Type:        "string",
+						Description: "Destination filename of the template as init would write it, e.g. LICENSE",
+					},
+					"profile": {
+						Type:        "string",
+						Description: "Named set of template files to look in (default: \"default\")",
+					},
+					"rename": {
+						Type:                 "object",
+						Description:          "Map of embedded destination names to replacement names, applied as in init",
+						AdditionalProperties: &Property{Type: "string"},
+					},
+					"variables": {
+						Type:                 "object",
+						Description:          "Values for {{NAME}} placeholders in destination names, applied as in init",
+						AdditionalProperties: &Property{Type: "string"},
+					},
+					"include_content": {
+						Type:        "boolean",
+						Description: "Also return the template's content as an embedded resource (text, or base64 blob for binary files)",
+					},
+				},
+				Required: []string{"name"},
+			},
+		},
+	}
+
+	// Optional files are toggled by a with_NAME boolean on init and clean.
+	for _, name := range conditionalFlags() {
+		for _, tool := range tools {
+			if tool.Name != "init" && tool.Name != "clean" {
+				continue
+			}
+			tool.InputSchema.Properties["with_"+name] = Property{
+				Type:        "boolean",
+				Description: fmt.Sprintf("Also include the optional files that require %q", name),
+			}
+		}
+	}
+
+	return tools
+}
+
+// handleToolsList returns one page of tools. The cursor is the opaque offset
+// of the next page; a page size of zero or less returns every tool at once.
+func (s *mcpServer) handleToolsList(req JSONRPCRequest) []byte {
+	var params ToolsListParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return errorResponse(req.ID, -32602, "Invalid params")
+		}
+	}
+
+	tools := s.tools()
+
+	start := 0
+	if params.Cursor != "" {
+		n, err := strconv.Atoi(params.Cursor)
+		if err != nil || n < 0 || n > len(tools) {
+			return errorResponse(req.ID, -32602, "Invalid cursor")
+		}
+		start = n
+	}
+
+	end := len(tools)
+	if s.ToolsPageSize > 0 && start+s.ToolsPageSize < end {
+		end = start + s.ToolsPageSize
+	}
+
+	result := ToolsListResult{Tools: tools[start:end]}
+	if end < len(tools) {
+		result.NextCursor = strconv.Itoa(end)
+	}
+	return resultMessage(req.ID, result)
+}
+
+func (s *mcpServer) handleToolsCall(ctx context.Context, req JSONRPCRequest) []byte {
+	var params ToolCallParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return errorResponse(req.ID, -32602, "Invalid params")
+	}
+
+	if err := s.checkToolEnabled(params.Name); err != nil {
+		return errorResponse(req.ID, -32602, err.Error())
+	}
+
+	var response []byte
+	switch params.Name {
+	case "init":
+		response = s.callInit(ctx, req, params)
+	case "clean":
+		response = s.callClean(ctx, req, params)
+	case "describe_file":
+		response = s.callDescribeFile(req, params)
+	case "stats":
+		response = s.callStats(req)
+	default:
+		return errorResponse(req.ID, -32602, "Unknown tool")
+	}
+
+	s.usage.mu.Lock()
+	s.usage.calls[params.Name]++
+	s.usage.mu.Unlock()
+	return response
+}
+
+func (s *mcpServer) callInit(ctx context.Context, req JSONRPCRequest, params ToolCallParams) []byte {
+	directories, err := directoriesArg(params.Arguments)
+	if err != nil {
+		return errorResponse(req.ID, -32602, err.Error())
+	}
+	opts, warnings, err := s.toolOptions(params.Arguments)
+	if err != nil {
+		return errorResponse(req.ID, -32602, err.Error())
+	}
+
+	if err := initcore.CheckTotalSize(opts.Files, s.MaxTotalSize); err != nil {
+		return errorResponse(req.ID, -32603, fmt.Sprintf("Init failed: %v", err))
+	}
+
+	preview, ok := params.Arguments["preview"].(bool)
+	if _, present := params.Arguments["preview"]; present && !ok {
+		return errorResponse(req.ID, -32602, "Invalid 'preview' parameter")
+	}
+	if preview {
+		return s.preview(req, directories, opts)
+	}
+
+	if _, many := params.Arguments["directories"]; many {
+		return s.initDirectories(ctx, req, params, directories, opts, warnings)
+	}
+
+	directory := directories[0]
+	result, err := initcore.WriteFilesContext(ctx, directory, opts)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errorResponse(req.ID, -32603, fmt.Sprintf("Init timed out after %s; partial writes were rolled back", s.CallTimeout))
+	}
+	if err != nil {
+		return s.toolError(req.ID, fmt.Sprintf("Init failed: %v", err), classifyInitError(err, directory, opts))
+	}
+
+	addWarnings(result, warnings)
+	s.countCreated(result)
+	return toolResult(req.ID, result)
+}
+
+// initDirectories writes the file set into each directory in turn and
+// returns one entry per directory: its Result, or a directoryError if it
+// failed and continue_on_error is set. Otherwise the first failure ends the
+// call with an error; directories already written are left in place.
+func (s *mcpServer) initDirectories(ctx context.Context, req JSONRPCRequest, params ToolCallParams, directories []string, opts initcore.Options, warnings []string) []byte {
+	continueOnError, ok := params.Arguments["continue_on_error"].(bool)
+	if _, present := params.Arguments["continue_on_error"]; present && !ok {
+		return errorResponse(req.ID, -32602, "Invalid 'continue_on_error' parameter")
+	}
+
+	results := make([]any, 0, len(directories))
+	for i, directory := range directories {
+		result, err := initcore.WriteFilesContext(ctx, directory, opts)
+		if err != nil && !continueOnError {
+			msg := fmt.Sprintf("Init failed in %s after %d of %d directories: %v", directory, i, len(directories), err)
+			return s.toolError(req.ID, msg, classifyInitError(err, directory, opts))
+		}
+		if err != nil {
+			results = append(results, directoryError{Directory: directory, Error: err.Error()})
+			continue
+		}
+		addWarnings(result, warnings)
+		s.countCreated(result)
+		results = append(results, result)
+	}
+
+	return toolResult(req.ID, results)
+}
+
+// countCreated adds result's created files to the usage counters.
+func (s *mcpServer) countCreated(result *initcore.Result) {
+	s.usage.mu.Lock()
+	s.usage.filesCreated += len(result.FilesCreated)
+	s.usage.mu.Unlock()
+}
+
+// preview answers an init call made with preview set: for each
+// directory, the planned operations as JSON followed by a short diff for
+// each destination that already exists. Nothing is written.
+func (s *mcpServer) preview(req JSONRPCRequest, directories []string, opts initcore.Options) []byte {
+	var content []ContentItem
+	for _, directory := range directories {
+		items, err := previewContent(directory, opts)
+		if err != nil {
+			return s.toolError(req.ID, fmt.Sprintf("Preview failed: %v", err), classifyInitError(err, directory, opts))
+		}
+		content = append(content, items...)
+	}
+
+	return resultMessage(req.ID, ToolCallResult{Content: content})
+}
+
+// previewContent returns the preview content items for one directory.
+func previewContent(directory string, opts initcore.Options) ([]ContentItem, error) {
+	ops, err := initcore.Plan(directory, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	planned, err := json.Marshal(ops)
+	if err != nil {
+		return nil, err
+	}
+	content := []ContentItem{{Type: "text", Text: string(planned)}}
+
+	byPath := make(map[string]initcore.File, len(opts.Files))
+	for _, f := range opts.Files {
+		byPath[filepath.Join(directory, f.DestName)] = f
+	}
+	for _, op := range ops {
+		if op.Action == initcore.ActionSkip {
+			continue
+		}
+		// With relative_paths the operation's path is relative to the
+		// directory.
+		path := op.Path
+		if opts.RelativePaths {
+			path = filepath.Join(directory, path)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		rendered := opts.Render(byPath[path])
+		if info.Size() > maxDiffSize || len(rendered) > maxDiffSize {
+			content = append(content, ContentItem{
+				Type: "text",
+				Text: fmt.Sprintf("%s already exists:\n%s", op.Path, tooLargeToDiff(fmt.Sprintf("%d and %d bytes, over the %d-byte limit", info.Size(), len(rendered), maxDiffSize))),
+			})
+			continue
+		}
+		existing, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if isBinary(existing) || isBinary(rendered) {
+			// A line diff means nothing here; show the template instead.
+			status := "differs"
+			if bytes.Equal(existing, rendered) {
+				status = "is identical"
+			}
+			content = append(content,
+				ContentItem{Type: "text", Text: fmt.Sprintf("%s already exists (binary, not diffed); the template %s:", op.Path, status)},
+				resourceItem("file://"+filepath.ToSlash(path), rendered),
+			)
+			continue
+		}
+		diff := shortDiff(string(existing), string(rendered))
+		if diff == "" {
+			diff = "(identical)"
+		}
+		content = append(content, ContentItem{
+			Type: "text",
+			Text: fmt.Sprintf("%s already exists:\n%s", op.Path, diff),
+		})
+	}
+
+	return content, nil
+}
+
+func (s *mcpServer) callClean(ctx context.Context, req JSONRPCRequest, params ToolCallParams) []byte {
+	directory, err := directoryArg(params.Arguments)
+	if err != nil {
+		return errorResponse(req.ID, -32602, err.Error())
+	}
+	opts, warnings, err := s.toolOptions(params.Arguments)
+	if err != nil {
+		return errorResponse(req.ID, -32602, err.Error())
+	}
+
+	result, err := initcore.CleanContext(ctx, directory, opts)
+	if err != nil {
+		return s.toolError(req.ID, fmt.Sprintf("Clean failed: %v", err), classifyInitError(err, directory, opts))
+	}
+	addWarnings(result, warnings)
+
+	return toolResult(req.ID, result)
+}
+
+// toolOptions parses the arguments shared by the init and clean tools into
+// options for initcore.
+func (s *mcpServer) toolOptions(args map[string]any) (initcore.Options, []string, error) {
+	opts := initcore.Options{Retries: s.Retries, Logger: s.Logger, TemplateVersion: TemplateVersion}
+	var warnings []string
+
+	renames, err := stringMapArg(args, "rename")
+	if err != nil {
+		return opts, nil, err
+	}
+
+	opts.Variables, err = stringMapArg(args, "variables")
+	if err != nil {
+		return opts, nil, err
+	}
+
+	relative, ok := args["relative_paths"].(bool)
+	if _, present := args["relative_paths"]; present && !ok {
+		return opts, nil, errors.New("Invalid 'relative_paths' parameter")
+	}
+	opts.RelativePaths = relative
+
+	force, ok := args["force"].(bool)
+	if _, present := args["force"]; present && !ok {
+		return opts, nil, errors.New("Invalid 'force' parameter")
+	}
+	opts.Force = force
+
+	ifEmpty, ok := args["if_empty"].(bool)
+	if _, present := args["if_empty"]; present && !ok {
+		return opts, nil, errors.New("Invalid 'if_empty' parameter")
+	}
+	opts.RequireEmpty = ifEmpty
+
+	profile, ok := args["profile"].(string)
+	if _, present := args["profile"]; present && !ok {
+		return opts, nil, errors.New("Invalid 'profile' parameter")
+	}
+
+	files, err := selectProfile(profile)
+	if err != nil {
+		return opts, nil, err
+	}
+
+	opts.Files, err = initcore.ApplyRenames(files, renames)
+	if err != nil {
+		return opts, nil, err
+	}
+
+	for _, name := range conditionalFlags() {
+		arg := "with_" + name
+		on, ok := args[arg].(bool)
+		if _, present := args[arg]; present && !ok {
+			return opts, nil, fmt.Errorf("Invalid '%s' parameter", arg)
+		}
+		if on {
+			opts.Enable = append(opts.Enable, name)
+		}
+	}
+
+	expandEnv, ok := args["expand_env"].(bool)
+	if _, present := args["expand_env"]; present && !ok {
+		return opts, nil, errors.New("Invalid 'expand_env' parameter")
+	}
+	if expandEnv {
+		var unset, denied []string
+		opts.Files, unset = initcore.ExpandEnvFunc(opts.Files, func(name string) (string, bool) {
+			if !slices.Contains(s.AllowEnv, name) {
+				denied = append(denied, name)
+				return "", true
+			}
+			return os.LookupEnv(name)
+		})
+		if len(denied) > 0 {
+			slices.Sort(denied)
+			return opts, nil, fmt.Errorf("expand_env: %s not allowed; the server only expands variables named with --allow-env", strings.Join(slices.Compact(denied), ", "))
+		}
+		for _, name := range unset {
+			s.Logger.Warn("environment variable is not set; expanded to empty", "name", name)
+			warnings = append(warnings, fmt.Sprintf("environment variable %s is not set; expanded to empty", name))
+		}
+	}
+
+	opts.Files, err = initcore.SubstituteNames(opts.Files, opts.Variables, false)
+	if err != nil {
+		return opts, nil, err
+	}
+
+	return opts, warnings, nil
+}
+
+// classifyInitError builds structured error data for a failed init call.
+// For collisions it lists every conflicting destination, not just the
+// first one hit. It returns nil for errors with no useful detail.
+func classifyInitError(err error, directory string, opts initcore.Options) any {
+	var pathErr *fs.PathError
+	switch {
+	case errors.Is(err, initcore.ErrFileExists):
+		data := &ErrorData{Kind: "collision"}
+		var collision *initcore.CollisionError
+		if errors.As(err, &collision) {
+			data.Path = collision.Path
+		}
+		if ops, planErr := initcore.Plan(directory, opts); planErr == nil {
+			for _, op := range ops {
+				if op.Conflict {
+					data.Conflicts = append(data.Conflicts, op.Path)
+				}
+			}
+			slices.Sort(data.Conflicts)
+		}
+		return data
+	case errors.Is(err, initcore.ErrSymlink):
+		return &ErrorData{Kind: "symlink"}
+	case errors.Is(err, initcore.ErrNotEmpty):
+		return &ErrorData{Kind: "not_empty", Path: directory}
+	case errors.Is(err, initcore.ErrInProgress):
+		return &ErrorData{Kind: "in_progress", Path: directory}
+	case errors.Is(err, initcore.ErrNotDirectory), errors.Is(err, fs.ErrNotExist):
+		return &ErrorData{Kind: "no_directory", Path: directory}
+	case errors.Is(err, fs.ErrPermission) && errors.As(err, &pathErr):
+		return &ErrorData{Kind: "permission", Path: pathErr.Path}
+	case errors.As(err, &pathErr):
+		return &ErrorData{Kind: "io", Path: pathErr.Path}
+	default:
+		return nil
+	}
+}
+
+// directoryArg reads the required 'directory' argument.
+func directoryArg(args map[string]any) (string, error) {
+	directory, ok := args["directory"].(string)
+	if !ok || directory == "" {
+		return "", errors.New("Missing or invalid 'directory' parameter")
+	}
+	return directory, nil
+}
+
+// directoriesArg reads the 'directories' array argument, falling back to the
+// single 'directory' argument when it is absent.
+func directoriesArg(args map[string]any) ([]string, error) {
+	raw, ok := args["directories"]
+	if !ok {
+		directory, err := directoryArg(args)
+		if err != nil {
+			return nil, err
+		}
+		return []string{directory}, nil
+	}
+
+	list, ok := raw.([]any)
+	if !ok || len(list) == 0 {
+		return nil, errors.New("Invalid 'directories' parameter: expected a non-empty array of strings")
+	}
+	directories := make([]string, len(list))
+	for i, v := range list {
+		directory, ok := v.(string)
+		if !ok || directory == "" {
+			return nil, errors.New("Invalid 'directories' parameter: expected a non-empty array of strings")
+		}
+		directories[i] = directory
+	}
+	return directories, nil
+}
+
+// stringMapArg reads an optional object argument whose values are all
+// strings. A missing argument yields a nil map.
+func stringMapArg(args map[string]any, name string) (map[string]string, error) {
+	raw, ok := args[name]
+	if !ok {
+		return nil, nil
+	}
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("Invalid '%s' parameter: expected an object", name)
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("Invalid '%s' value for %s: expected a string", name, k)
+		}
+		out[k] = str
+	}
+	return out, nil
+}
+
+func (s *mcpServer) callDescribeFile(req JSONRPCRequest, params ToolCallParams) []byte {
+	name, ok := params.Arguments["name"].(string)
+	if !ok || name == "" {
+		return errorResponse(req.ID, -32602, "Missing or invalid 'name' parameter")
+	}
+
+	includeContent, ok := params.Arguments["include_content"].(bool)
+	if _, present := params.Arguments["include_content"]; present && !ok {
+		return errorResponse(req.ID, -32602, "Invalid 'include_content' parameter")
+	}
+
+	// Resolve the file as init would, so profile, rename and variables
+	// select the same template set.
+	opts, _, err := s.toolOptions(params.Arguments)
+	if err != nil {
+		return errorResponse(req.ID, -32602, err.Error())
+	}
+	info, err := initcore.Describe(opts.Files, name)
+	if err != nil {
+		return errorResponse(req.ID, -32602, err.Error())
+	}
+	if !includeContent {
+		return toolResult(req.ID, info)
+	}
+
+	jsonResult, err := json.Marshal(info)
+	if err != nil {
+		return errorResponse(req.ID, -32603, "Failed to marshal result")
+	}
+	i := slices.IndexFunc(opts.Files, func(f initcore.File) bool { return f.DestName == name })
+	return resultMessage(req.ID, ToolCallResult{Content: []ContentItem{
+		{Type: "text", Text: string(jsonResult)},
+		resourceItem(templateURI(name), opts.Files[i].Content),
+	}})
+}
+
+// callStats reports tool usage since the server started. The call being
+// answered is not yet counted.
+func (s *mcpServer) callStats(req JSONRPCRequest) []byte {
+	s.usage.mu.Lock()
+	stats := ServerStats{
+		ToolCalls:     maps.Clone(s.usage.calls),
+		FilesCreated:  s.usage.filesCreated,
+		UptimeSeconds: int64(time.Since(s.usage.started).Seconds()),
+
+		TemplateVersion: TemplateVersion,
+	}
+	s.usage.mu.Unlock()
+
+	return toolResult(req.ID, stats)
+}
+
+// toolResult returns the response for id carrying v, marshaled as JSON, as
+// a single text content item.
+func toolResult(id any, v any) []byte {
+	jsonResult, err := json.Marshal(v)
+	if err != nil {
+		return errorResponse(id, -32603, "Failed to marshal result")
+	}
+
+	response := ToolCallResult{
+		Content: []ContentItem{
+			{
+				Type: "text",
+				Text: string(jsonResult),
+			},
+		},
+	}
+
+	return resultMessage(id, response)
+}
+
+// resultMessage returns the encoded success response for id, or nil if it
+// does not marshal.
+func resultMessage(id any, result any) []byte {
+	resp := JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result:  result,
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to marshal response: %v\n", err)
+		return nil
+	}
+	return data
+}
+
+// errorResponse returns the encoded error response for id.
+func errorResponse(id any, code int, message string) []byte {
+	return errorDataResponse(id, code, message, nil)
+}
+
+// errorMessage returns the encoded JSON-RPC error response for id.
+func errorMessage(id any, code int, message string, errData any) ([]byte, error) {
+	return json.Marshal(JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error: &Error{
+			Code:    code,
+			Message: message,
+			Data:    errData,
+		},
+	})
+}
+
+// toolError returns the response for a failed tool call with errData, as built by
+// classifyInitError. The code is the one ErrorCodes maps its kind to, or
+// -32603.
+func (s *mcpServer) toolError(id any, message string, errData any) []byte {
+	code := -32603
+	if data, ok := errData.(*ErrorData); ok {
+		if mapped, ok := s.ErrorCodes[data.Kind]; ok {
+			code = mapped
+		}
+	}
+	return errorDataResponse(id, code, message, errData)
+}
+
+// errorDataResponse returns the encoded error response for id, carrying
+// optional structured data, or nil if it does not marshal.
+func errorDataResponse(id any, code int, message string, errData any) []byte {
+	data, err := errorMessage(id, code, message, errData)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to marshal error response: %v\n", err)
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func FuzzParseAndDispatch(f *testing.F) {
+	for _, seed := range []string{
+		``,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","id":"a","method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"stats"}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"describe_file","arguments":{"name":"LICENSE"}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"logging/setLevel","params":{"level":"debug"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":{},"method":"tools/list"}`,
+		`{"jsonrpc":"1.0","id":null,"method":"nope"}`,
+		`[1,2]`,
+		`null`,
+		`{"id":1`,
+		"\xff",
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, line []byte) {
+		// Read-only, so no input can make the server write files.
+		s := newTestServer(serverOptions{ReadOnly: true, Strict: len(line)%2 == 0})
+		response, ok := s.parseAndDispatch(line)
+		if !ok {
+			if response != nil {
+				t.Fatalf("ok is false but response is %q", response)
+			}
+			return
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			t.Fatalf("blank input got response %q", response)
+		}
+
+		var resp map[string]json.RawMessage
+		if err := json.Unmarshal(response, &resp); err != nil {
+			t.Fatalf("response %q is not a JSON object: %v", response, err)
+		}
+		if string(resp["jsonrpc"]) != `"2.0"` {
+			t.Fatalf("response %q lacks jsonrpc 2.0", response)
+		}
+		if _, ok := resp["id"]; !ok {
+			t.Fatalf("response %q lacks an id", response)
+		}
+		_, hasResult := resp["result"]
+		_, hasError := resp["error"]
+		if hasResult == hasError {
+			t.Fatalf("response %q must have exactly one of result and error", response)
+		}
+	})
+}
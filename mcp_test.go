@@ -0,0 +1,436 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hegner123/init/initcore"
+)
+
+// newTestServer returns a server with opts that writes nothing anywhere.
+func newTestServer(opts serverOptions) *mcpServer {
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	return &mcpServer{
+		serverOptions: opts,
+		out:           io.Discard,
+		ctx:           context.Background(),
+		usage:         usageStats{started: time.Now(), calls: make(map[string]int)},
+	}
+}
+
+func TestReadContentLengthMessage(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("Content-Length: 2\r\n\r\n{}"))
+	msg, err := readContentLengthMessage(r)
+	if err != nil || string(msg) != "{}" {
+		t.Fatalf("got %q, %v; want {}", msg, err)
+	}
+}
+
+func TestReadContentLengthMessageTooLarge(t *testing.T) {
+	for _, length := range []string{"9000000000000000000", "16777217"} {
+		r := bufio.NewReader(strings.NewReader("Content-Length: " + length + "\r\n\r\n{}"))
+		if _, err := readContentLengthMessage(r); err == nil {
+			t.Errorf("Content-Length %s: want an error", length)
+		}
+	}
+}
+
+func TestReadLineMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "one per line",
+			input: "{\"id\":1}\n{\"id\":2}\n",
+			want:  []string{`{"id":1}`, `{"id":2}`},
+		},
+		{
+			name:  "pretty-printed",
+			input: "{\n  \"id\": 1\n}\n{\"id\":2}\n",
+			want:  []string{"{\n  \"id\": 1\n}", `{"id":2}`},
+		},
+		{
+			name:  "truncated then valid",
+			input: "{\"jsonrpc\":\"2.0\",\"id\":1,\"method\":\"tools/list\"\n{\"jsonrpc\":\"2.0\",\"id\":2,\"method\":\"tools/list\"}\n",
+			want:  []string{`{"jsonrpc":"2.0","id":1,"method":"tools/list"`, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`},
+		},
+		{
+			name:  "unterminated string",
+			input: "{\"id\":\"abc\n{\"id\":2}\n{\"id\":3}\n",
+			want:  []string{`{"id":"abc`, `{"id":2}`, `{"id":3}`},
+		},
+		{
+			name:  "not JSON",
+			input: "garbage\n{\"id\":2}\n",
+			want:  []string{"garbage", `{"id":2}`},
+		},
+		{
+			name:  "truncated at end of input",
+			input: "{\"id\":1}\n{\"id\":",
+			want:  []string{`{"id":1}`, `{"id":`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &lineReader{Reader: bufio.NewReader(strings.NewReader(tt.input))}
+			var got []string
+			for {
+				msg, err := readLineMessage(r)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, string(msg))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadLineMessageTooLarge(t *testing.T) {
+	for _, input := range []string{
+		"[" + strings.Repeat("1,", maxMessageSize/2) + "1]\n",
+		"[" + strings.Repeat(strings.Repeat("1,", 1<<19)+"\n", 17) + "1]\n",
+	} {
+		r := &lineReader{Reader: bufio.NewReader(strings.NewReader(input))}
+		if _, err := readLineMessage(r); err == nil {
+			t.Error("want an error for a message over the limit")
+		}
+	}
+}
+
+func TestCallToolTimeout(t *testing.T) {
+	dir := t.TempDir()
+	release := make(chan struct{})
+	s := newTestServer(serverOptions{CallTimeout: time.Millisecond})
+	s.Logger = slog.New(blockingHandler{release})
+
+	// initcore logs each file it writes, and the log blocks until
+	// release, so the call is stuck in its first write.
+	line := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"init","arguments":{"directory":` + strconv.Quote(dir) + `}}}`
+	response, ok := s.parseAndDispatch([]byte(line))
+	close(release)
+	if !ok || !strings.Contains(string(response), "timed out") {
+		t.Fatalf("got %s, want a timeout error", response)
+	}
+
+	// Once released, the call sees its context cancelled and rolls back.
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d entries left in %s after the timed-out call", len(entries), dir)
+		}
+	}
+}
+
+// blockingHandler is a slog.Handler whose Handle waits for release.
+type blockingHandler struct{ release chan struct{} }
+
+func (h blockingHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h blockingHandler) Handle(context.Context, slog.Record) error {
+	<-h.release
+	return nil
+}
+func (h blockingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h blockingHandler) WithGroup(string) slog.Handler      { return h }
+
+func TestRequestIDs(t *testing.T) {
+	tests := []struct {
+		id       string
+		wantID   string
+		wantCode int
+	}{
+		{id: `1`, wantID: `1`, wantCode: -32601},
+		{id: `"a"`, wantID: `"a"`, wantCode: -32601},
+		{id: `null`, wantID: `null`, wantCode: -32601},
+		{id: `{"a":1}`, wantID: `null`, wantCode: -32600},
+		{id: `[1]`, wantID: `null`, wantCode: -32600},
+		{id: `true`, wantID: `null`, wantCode: -32600},
+	}
+
+	for _, tt := range tests {
+		s := newTestServer(serverOptions{})
+		response, ok := s.parseAndDispatch([]byte(`{"jsonrpc":"2.0","id":` + tt.id + `,"method":"no/such/method"}`))
+		if !ok {
+			t.Errorf("id %s: got no response", tt.id)
+			continue
+		}
+		var resp struct {
+			ID    json.RawMessage `json:"id"`
+			Error *Error          `json:"error"`
+		}
+		if err := json.Unmarshal(response, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if string(resp.ID) != tt.wantID || resp.Error == nil || resp.Error.Code != tt.wantCode {
+			t.Errorf("id %s: got %s, want id %s and code %d", tt.id, response, tt.wantID, tt.wantCode)
+		}
+	}
+}
+
+func TestNotificationsGetNoResponse(t *testing.T) {
+	for _, line := range []string{
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1}}`,
+		`{"jsonrpc":"2.0","method":"no/such/method"}`,
+		`{"jsonrpc":"2.0","method":"tools/list"}`,
+		`{"jsonrpc":"1.0","method":"tools/list"}`,
+	} {
+		s := newTestServer(serverOptions{Strict: true})
+		if response, ok := s.parseAndDispatch([]byte(line)); ok || response != nil {
+			t.Errorf("%s: got response %s, want none", line, response)
+		}
+	}
+}
+
+// draft07Types are the JSON Schema draft-07 simple types.
+var draft07Types = []string{"array", "boolean", "integer", "null", "number", "object", "string"}
+
+// schemaKeywords are the draft-07 keywords the tool schemas may use.
+var schemaKeywords = []string{"type", "description", "properties", "required", "anyOf", "items", "minItems", "additionalProperties"}
+
+func TestToolSchemasAreDraft07(t *testing.T) {
+	for _, tool := range toolDefinitions() {
+		data, err := json.Marshal(tool.InputSchema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var schema map[string]any
+		if err := json.Unmarshal(data, &schema); err != nil {
+			t.Fatal(err)
+		}
+		if schema["type"] != "object" {
+			t.Errorf("%s: inputSchema type = %v, want object", tool.Name, schema["type"])
+		}
+		checkSchema(t, tool.Name, schema)
+	}
+}
+
+// checkSchema reports every way schema, found at path, is not a valid
+// draft-07 schema built from schemaKeywords.
+func checkSchema(t *testing.T, path string, schema map[string]any) {
+	t.Helper()
+	for key := range schema {
+		if !slices.Contains(schemaKeywords, key) {
+			t.Errorf("%s: unexpected keyword %q", path, key)
+		}
+	}
+
+	typ, _ := schema["type"].(string)
+	if !slices.Contains(draft07Types, typ) {
+		t.Errorf("%s: type %v is not a draft-07 type", path, schema["type"])
+	}
+	if d, ok := schema["description"]; ok {
+		if _, ok := d.(string); !ok {
+			t.Errorf("%s: description is not a string", path)
+		}
+	}
+
+	properties := map[string]any{}
+	if p, ok := schema["properties"]; ok {
+		properties, ok = p.(map[string]any)
+		if !ok || typ != "object" {
+			t.Errorf("%s: properties must be an object on an object schema", path)
+		}
+		for name, sub := range properties {
+			checkSubschema(t, path+"."+name, sub)
+		}
+	}
+	if r, ok := schema["required"]; ok {
+		checkRequired(t, path+".required", r, properties)
+	}
+
+	if a, ok := schema["anyOf"]; ok {
+		branches, ok := a.([]any)
+		if !ok || len(branches) == 0 {
+			t.Errorf("%s: anyOf must be a non-empty array", path)
+		}
+		for _, branch := range branches {
+			b, ok := branch.(map[string]any)
+			if !ok {
+				t.Errorf("%s: anyOf entry %v is not a schema", path, branch)
+				continue
+			}
+			for key, value := range b {
+				if key != "required" {
+					t.Errorf("%s: anyOf entry has unexpected keyword %q", path, key)
+					continue
+				}
+				checkRequired(t, path+".anyOf.required", value, properties)
+			}
+		}
+	}
+
+	if items, ok := schema["items"]; ok {
+		if typ != "array" {
+			t.Errorf("%s: items on a %s schema", path, typ)
+		}
+		checkSubschema(t, path+"[]", items)
+	}
+	if m, ok := schema["minItems"]; ok {
+		if n, ok := m.(float64); !ok || n < 0 || n != float64(int(n)) || typ != "array" {
+			t.Errorf("%s: minItems %v must be a non-negative integer on an array schema", path, m)
+		}
+	}
+	if additional, ok := schema["additionalProperties"]; ok {
+		if typ != "object" {
+			t.Errorf("%s: additionalProperties on a %s schema", path, typ)
+		}
+		if _, ok := additional.(bool); !ok {
+			checkSubschema(t, path+".*", additional)
+		}
+	}
+}
+
+// checkSubschema checks that v, found at path, is a schema object.
+func checkSubschema(t *testing.T, path string, v any) {
+	t.Helper()
+	sub, ok := v.(map[string]any)
+	if !ok {
+		t.Errorf("%s: %v is not a schema object", path, v)
+		return
+	}
+	checkSchema(t, path, sub)
+}
+
+// checkRequired checks that v is an array of distinct property names.
+func checkRequired(t *testing.T, path string, v any, properties map[string]any) {
+	t.Helper()
+	names, ok := v.([]any)
+	if !ok {
+		t.Errorf("%s: %v is not an array", path, v)
+		return
+	}
+	seen := map[string]bool{}
+	for _, n := range names {
+		name, ok := n.(string)
+		if !ok || seen[name] {
+			t.Errorf("%s: %v is not a distinct string", path, n)
+			continue
+		}
+		seen[name] = true
+		if _, ok := properties[name]; !ok {
+			t.Errorf("%s: %q is not a declared property", path, name)
+		}
+	}
+}
+
+func TestExpandEnvAllowlist(t *testing.T) {
+	t.Setenv("INIT_TEST_ALLOWED", "allowed")
+	t.Setenv("INIT_TEST_SECRET", "secret")
+	s := newTestServer(serverOptions{AllowEnv: []string{"INIT_TEST_ALLOWED"}})
+
+	opts, _, err := s.toolOptions(map[string]any{
+		"expand_env": true,
+		"rename":     map[string]any{"LICENSE": "LICENSE-$INIT_TEST_ALLOWED"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(opts.Files, func(f initcore.File) bool { return f.DestName == "LICENSE-allowed" }) {
+		t.Error("allowed variable was not expanded")
+	}
+
+	_, _, err = s.toolOptions(map[string]any{
+		"expand_env": true,
+		"rename":     map[string]any{"LICENSE": "$INIT_TEST_SECRET"},
+	})
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("err = %v, want a refusal that does not reveal the value", err)
+	}
+}
+
+func TestPreviewContentTooLarge(t *testing.T) {
+	dir := t.TempDir()
+	large := strings.Repeat("x", maxDiffSize+1)
+	if err := os.WriteFile(filepath.Join(dir, "LICENSE"), []byte(large), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := initcore.Options{
+		Files: []initcore.File{{Content: []byte("license\n"), DestName: "LICENSE"}},
+		Force: true,
+	}
+
+	content, err := previewContent(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(content) != 2 || !strings.Contains(content[1].Text, "file too large to diff") {
+		t.Errorf("preview = %+v, want a too-large summary for LICENSE", content)
+	}
+}
+
+func TestClassifyInitErrorSortsConflicts(t *testing.T) {
+	dir := t.TempDir()
+	opts := initcore.Options{Files: []initcore.File{
+		{Content: []byte("z\n"), DestName: "z.md"},
+		{Content: []byte("m\n"), DestName: "docs/m.md"},
+		{Content: []byte("a\n"), DestName: "a.md"},
+	}}
+	for _, f := range opts.Files {
+		path := filepath.Join(dir, f.DestName)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("existing\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := initcore.WriteFiles(dir, opts)
+	data, ok := classifyInitError(err, dir, opts).(*ErrorData)
+	if !ok {
+		t.Fatalf("classifyInitError(%v) is not a collision", err)
+	}
+	if len(data.Conflicts) != 3 || !slices.IsSorted(data.Conflicts) {
+		t.Errorf("Conflicts = %q, want all three sorted", data.Conflicts)
+	}
+}
+
+func TestDescribeFileUsesTemplateSet(t *testing.T) {
+	s := newTestServer(serverOptions{})
+	call := func(args string) map[string]json.RawMessage {
+		t.Helper()
+		line := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"describe_file","arguments":` + args + `}}`
+		response, _ := s.parseAndDispatch([]byte(line))
+		var msg map[string]json.RawMessage
+		if err := json.Unmarshal(response, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	msg := call(`{"name":"LICENSE.txt","rename":{"LICENSE":"LICENSE.txt"}}`)
+	if _, failed := msg["error"]; failed || !strings.Contains(string(msg["result"]), "LICENSE.txt") {
+		t.Errorf("renamed file: got %s", msg)
+	}
+	if msg := call(`{"name":"LICENSE","rename":{"LICENSE":"LICENSE.txt"}}`); msg["error"] == nil {
+		t.Errorf("original name after rename: got %s, want an error", msg)
+	}
+	if msg := call(`{"name":"LICENSE","profile":"no-such-profile"}`); msg["error"] == nil {
+		t.Errorf("unknown profile: got %s, want an error", msg)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hegner123/init/initcore"
+)
+
+// Output formats for CLI results.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// directoryError is the entry reported in place of a result for a directory
+// that failed when processing several directories with continue-on-error.
+type directoryError struct {
+	Directory string `json:"directory"`
+	Error     string `json:"error"`
+}
+
+// isTerminal reports whether f is attached to a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// formatText renders a CLI result as a short human-readable summary. With
+// color set, created files are shown in green and failures in red. relative
+// says the result's paths are already relative to their directory, as with
+// --relative-paths.
+func formatText(result any, color, relative bool) string {
+	var b strings.Builder
+	red := func(s string) string { return colorize(color, colorRed, s) }
+
+	switch r := result.(type) {
+	case []any:
+		for _, each := range r {
+			b.WriteString(formatText(each, color, relative))
+			b.WriteString("\n")
+		}
+	case directoryError:
+		fmt.Fprintln(&b, red(fmt.Sprintf("Failed in %s: %s", r.Directory, r.Error)))
+	case *initcore.Result:
+		if len(r.FilesCreated) > 0 {
+			var created strings.Builder
+			writeFileList(&created, "Created", r.Directory, r.FilesCreated, relative)
+			fmt.Fprintln(&b, colorize(color, colorGreen, strings.TrimSuffix(created.String(), "\n")))
+		}
+		writeFileList(&b, "Overwrote", r.Directory, r.FilesOverwritten, relative)
+		writeFileList(&b, "Appended to", r.Directory, r.FilesAppended, relative)
+		writeFileList(&b, "Removed", r.Directory, r.FilesRemoved, relative)
+		writeFileList(&b, "Skipped", r.Directory, r.FilesSkipped, relative)
+		writeFileList(&b, "Excluded", r.Directory, r.FilesExcluded, relative)
+		if b.Len() == 0 {
+			fmt.Fprintf(&b, "Nothing to do in %s\n", r.Directory)
+		}
+		if r.GitInitialized != nil {
+			if *r.GitInitialized {
+				b.WriteString("Initialized git repository\n")
+			} else {
+				fmt.Fprintln(&b, red("git init failed: "+r.GitError))
+			}
+		}
+	case []initcore.Operation:
+		for _, op := range r {
+			conflict := ""
+			if op.Conflict {
+				conflict = " " + red("(conflict: already exists)")
+			}
+			fmt.Fprintf(&b, "%-9s %s %6d bytes  %s%s\n", op.Action, op.Mode, op.Size, op.Path, conflict)
+		}
+	case []initcore.FileInfo:
+		for _, info := range r {
+			optional := ""
+			if info.RequiresFlag != "" {
+				optional = " (with --with-" + info.RequiresFlag + ")"
+			}
+			fmt.Fprintf(&b, "%s %6d bytes  %s%s\n", info.Mode, info.Size, info.DestName, optional)
+		}
+	case *initcore.VerifyReport:
+		for _, entry := range r.Files {
+			status := fmt.Sprintf("%-10s", entry.Status)
+			if entry.Status != initcore.VerifyOK {
+				status = red(status)
+			}
+			fmt.Fprintf(&b, "%s %s\n", status, entry.Path)
+		}
+		if r.OK {
+			fmt.Fprintf(&b, "%s matches the template set\n", r.Directory)
+		} else {
+			fmt.Fprintln(&b, red(r.Directory+" does not match the template set"))
+		}
+	default:
+		fmt.Fprintf(&b, "%v\n", r)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// writeFileList writes a line like "Created 2 files in /dir: A, B". Nothing
+// is written for an empty list. Paths are shown relative to directory;
+// relative says they already are.
+func writeFileList(b *strings.Builder, verb, directory string, paths []string, relative bool) {
+	if len(paths) == 0 {
+		return
+	}
+
+	names := slices.Clone(paths)
+	if !relative {
+		for i, p := range paths {
+			if rel, err := filepath.Rel(directory, p); err == nil {
+				names[i] = rel
+			}
+		}
+	}
+
+	noun := "files"
+	if len(paths) == 1 {
+		noun = "file"
+	}
+	fmt.Fprintf(b, "%s %d %s in %s: %s\n", verb, len(paths), noun, directory, strings.Join(names, ", "))
+}
+
+// addWarnings prepends warnings to result's own, if result is an
+// *initcore.Result.
+func addWarnings(result any, warnings []string) {
+	if r, ok := result.(*initcore.Result); ok && len(warnings) > 0 {
+		r.Warnings = append(slices.Clone(warnings), r.Warnings...)
+	}
+}
+
+// resultWarnings returns the warnings carried by result, a Result or
+// VerifyReport, including those of each entry when it is a
+// multi-directory array.
+func resultWarnings(result any) []string {
+	switch r := result.(type) {
+	case *initcore.Result:
+		return r.Warnings
+	case *initcore.VerifyReport:
+		return r.Warnings
+	case []any:
+		var warnings []string
+		for _, each := range r {
+			warnings = append(warnings, resultWarnings(each)...)
+		}
+		return warnings
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hegner123/init/initcore"
+)
+
+func TestFormatTextPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   *initcore.Result
+		relative bool
+		want     string
+	}{
+		{
+			name: "absolute",
+			result: &initcore.Result{
+				Directory:    "/p",
+				FilesCreated: []string{"/p/CONTRIBUTING.md", "/p/LICENSE"},
+				FilesSkipped: []string{"/p/docs/guide.md"},
+			},
+			want: "Created 2 files in /p: CONTRIBUTING.md, LICENSE\nSkipped 1 file in /p: " + filepath.Join("docs", "guide.md"),
+		},
+		{
+			name: "relative directory",
+			result: &initcore.Result{
+				Directory:    "d",
+				FilesCreated: []string{filepath.Join("d", "LICENSE")},
+			},
+			want: "Created 1 file in d: LICENSE",
+		},
+		{
+			name: "relative paths",
+			result: &initcore.Result{
+				Directory:        "d",
+				FilesCreated:     []string{"CONTRIBUTING.md", "LICENSE"},
+				FilesOverwritten: []string{"README.md"},
+				FilesRemoved:     []string{"OLD.md"},
+				FilesSkipped:     []string{"NOTES.md"},
+			},
+			relative: true,
+			want: "Created 2 files in d: CONTRIBUTING.md, LICENSE\n" +
+				"Overwrote 1 file in d: README.md\n" +
+				"Removed 1 file in d: OLD.md\n" +
+				"Skipped 1 file in d: NOTES.md",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatText(tt.result, false, tt.relative); got != tt.want {
+				t.Errorf("formatText =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
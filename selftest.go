@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/hegner123/init/initcore"
+)
+
+// runSelftest checks every profile's embedded files: each must decompress
+// if compressed, be non-empty, have a unique, local DestName, and survive a
+// write to a temporary directory byte for byte. It prints one line per check to w and reports
+// whether all of them passed. Files with identical content get a WARN line,
+// which does not fail the selftest.
+func runSelftest(w io.Writer) bool {
+	ok := true
+	check := func(name string, err error) {
+		if err != nil {
+			ok = false
+			fmt.Fprintf(w, "FAIL %s: %v\n", name, err)
+			return
+		}
+		fmt.Fprintf(w, "PASS %s\n", name)
+	}
+
+	for _, profile := range slices.Sorted(maps.Keys(profiles)) {
+		files, err := initcore.Decompress(profiles[profile])
+		check(profile+": decompression", err)
+		if err != nil {
+			continue
+		}
+		check(profile+": files present", checkContent(files))
+		check(profile+": destination names", checkDestNames(files))
+		for _, pair := range duplicateContent(files) {
+			fmt.Fprintf(w, "WARN %s: %s and %s have identical content\n", profile, pair[0], pair[1])
+		}
+		check(profile+": write round-trip", checkRoundTrip(files))
+	}
+
+	if ok {
+		fmt.Fprintln(w, "selftest passed")
+	} else {
+		fmt.Fprintln(w, "selftest FAILED")
+	}
+	return ok
+}
+
+// checkContent returns an error if files is empty or any file has no content.
+func checkContent(files []initcore.File) error {
+	if len(files) == 0 {
+		return fmt.Errorf("no files")
+	}
+	for _, f := range files {
+		if len(f.Content) == 0 {
+			return fmt.Errorf("%s is empty", f.DestName)
+		}
+	}
+	return nil
+}
+
+// checkDestNames returns an error if a DestName is duplicated or is not a
+// local path (absolute, empty, or escaping via "..").
+func checkDestNames(files []initcore.File) error {
+	seen := make(map[string]bool, len(files))
+	for _, f := range files {
+		if !filepath.IsLocal(f.DestName) {
+			return fmt.Errorf("%q is not a local path", f.DestName)
+		}
+		if seen[f.DestName] {
+			return fmt.Errorf("%q is used more than once", f.DestName)
+		}
+		seen[f.DestName] = true
+	}
+	return nil
+}
+
+// duplicateContent returns the DestNames of each pair of files whose
+// content is identical, which usually means a file was embedded twice by
+// mistake. Each file is paired with the first one that had its content.
+func duplicateContent(files []initcore.File) [][2]string {
+	var pairs [][2]string
+	first := make(map[[sha256.Size]byte]string, len(files))
+	for _, f := range files {
+		sum := sha256.Sum256(f.Content)
+		if name, ok := first[sum]; ok {
+			pairs = append(pairs, [2]string{name, f.DestName})
+			continue
+		}
+		first[sum] = f.DestName
+	}
+	return pairs
+}
+
+// checkRoundTrip writes files to a fresh temporary directory, with every
+// optional file enabled, and verifies each one reads back unchanged.
+func checkRoundTrip(files []initcore.File) error {
+	dir, err := os.MkdirTemp("", "init-selftest-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	var enable []string
+	for _, f := range files {
+		if f.RequiresFlag != "" {
+			enable = append(enable, f.RequiresFlag)
+		}
+	}
+	if _, err := initcore.WriteFiles(dir, initcore.Options{Files: files, Enable: enable}); err != nil {
+		return err
+	}
+	for _, f := range files {
+		got, err := os.ReadFile(filepath.Join(dir, f.DestName))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(got, f.Content) {
+			return fmt.Errorf("%s: content differs after writing", f.DestName)
+		}
+	}
+	return nil
+}
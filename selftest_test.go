@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hegner123/init/initcore"
+)
+
+func TestCheckRoundTripOptionalFiles(t *testing.T) {
+	files := []initcore.File{
+		{Content: []byte("readme\n"), DestName: "README.md"},
+		{Content: []byte("ci\n"), DestName: ".github/workflows/ci.yml", RequiresFlag: "ci"},
+	}
+	if err := checkRoundTrip(files); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+
+	"github.com/hegner123/init/initcore"
+)
+
+// fileStats aggregates the files written by a CLI run for --stats.
+type fileStats struct {
+	byExt       map[string]int
+	lines       int
+	largest     string
+	largestSize int
+}
+
+// collectStats computes statistics over the files in opts whose destination
+// appears in written, either joined onto dir or relative to it.
+func collectStats(dir string, opts initcore.Options, written []string) fileStats {
+	st := fileStats{byExt: make(map[string]int)}
+	for _, f := range opts.Files {
+		path := filepath.Join(dir, f.DestName)
+		rel, _ := filepath.Rel(dir, path)
+		if !slices.Contains(written, path) && !slices.Contains(written, rel) {
+			continue
+		}
+		content := opts.Render(f)
+
+		ext := filepath.Ext(f.DestName)
+		if ext == "" {
+			ext = "(none)"
+		}
+		st.byExt[ext]++
+
+		st.lines += bytes.Count(content, []byte("\n"))
+		if len(content) > 0 && content[len(content)-1] != '\n' {
+			st.lines++
+		}
+
+		if st.largest == "" || len(content) > st.largestSize {
+			st.largest, st.largestSize = f.DestName, len(content)
+		}
+	}
+	return st
+}
+
+// write prints the statistics as a short human-readable block.
+func (st fileStats) write(w io.Writer) {
+	total := 0
+	for _, ext := range slices.Sorted(maps.Keys(st.byExt)) {
+		fmt.Fprintf(w, "%-10s %d\n", ext, st.byExt[ext])
+		total += st.byExt[ext]
+	}
+	fmt.Fprintf(w, "Files: %d, lines: %d\n", total, st.lines)
+	if st.largest != "" {
+		fmt.Fprintf(w, "Largest: %s (%d bytes)\n", st.largest, st.largestSize)
+	}
+}